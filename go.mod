@@ -8,6 +8,7 @@ require (
 	github.com/Azure/go-autorest/autorest/adal v0.8.0 // indirect
 	github.com/aws/aws-sdk-go v1.25.31 // indirect
 	github.com/emicklei/go-restful v2.11.1+incompatible // indirect
+	github.com/evanphx/json-patch v4.5.0+incompatible
 	github.com/googleapis/gnostic v0.3.1 // indirect
 	github.com/gophercloud/gophercloud v0.6.0 // indirect
 	github.com/hashicorp/go-hclog v0.10.0 // indirect
@@ -19,10 +20,12 @@ require (
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/posener/complete v1.2.2 // indirect
 	github.com/ulikunitz/xz v0.5.6 // indirect
+	github.com/zclconf/go-cty v1.1.0
 	golang.org/x/tools v0.0.0-20200513154647-78b527d18275 // indirect
 	gopkg.in/yaml.v2 v2.2.8
 	k8s.io/api v0.18.2
 	k8s.io/apimachinery v0.18.2
 	k8s.io/client-go v0.18.2
 	sigs.k8s.io/kustomize/api v0.4.1
+	sigs.k8s.io/yaml v1.2.0
 )
@@ -4,8 +4,13 @@ import (
 	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"gopkg.in/yaml.v2"
 
 	"sigs.k8s.io/kustomize/api/filesys"
 	"sigs.k8s.io/kustomize/api/krusty"
@@ -13,14 +18,24 @@ import (
 	"sigs.k8s.io/kustomize/api/types"
 )
 
+// getIDFromResources hashes the canonical hashes of all resources,
+// so the ID does not depend on their order or serialization
 func getIDFromResources(rm resmap.ResMap) (s string, err error) {
-	h := sha512.New()
-
-	yaml, err := rm.AsYaml()
+	hashes, err := flattenKustomizationHashes(rm)
 	if err != nil {
-		return "", fmt.Errorf("ResMap AsYaml failed: %s", err)
+		return "", err
+	}
+
+	ids := make([]string, 0, len(hashes))
+	for id := range hashes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	h := sha512.New()
+	for _, id := range ids {
+		fmt.Fprintf(h, "%s=%s\n", id, hashes[id])
 	}
-	h.Write(yaml)
 
 	s = hex.EncodeToString(h.Sum(nil))
 
@@ -31,11 +46,46 @@ func dataSourceKustomization() *schema.Resource {
 	return &schema.Resource{
 		Read: kustomizationBuild,
 
-		Schema: map[string]*schema.Schema{
+		Schema: withVariableSubstitutionSchema(map[string]*schema.Schema{
 			"path": &schema.Schema{
 				Type:     schema.TypeString,
 				Required: true,
 			},
+			"files": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Build from these files in memory instead of from disk. Keys are file paths relative to the in-memory root, path is the entry point relative to the same root.",
+			},
+			"namespace": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Override the namespace of all resources.",
+			},
+			"name_prefix": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Prefix the names of all resources.",
+			},
+			"name_suffix": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Suffix the names of all resources.",
+			},
+			"common_labels": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Add labels to all resources and selectors.",
+			},
+			"common_annotations": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Add annotations to all resources.",
+			},
+			"images":   imagesSchema(),
+			"replicas": replicasSchema(),
 			"ids": &schema.Schema{
 				Type:     schema.TypeSet,
 				Computed: true,
@@ -46,7 +96,19 @@ func dataSourceKustomization() *schema.Resource {
 				Computed: true,
 				Elem:     &schema.Schema{Type: schema.TypeString},
 			},
-		},
+			"hashes": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Canonical hash of each resource, that only changes if the resource does.",
+			},
+			"sensitive_manifests": &schema.Schema{
+				Type:      schema.TypeMap,
+				Computed:  true,
+				Sensitive: true,
+				Elem:      &schema.Schema{Type: schema.TypeString},
+			},
+		}),
 	}
 }
 
@@ -61,30 +123,60 @@ func runKustomizeBuildWithFileSys(fSys filesys.FileSystem, path string) (rm resm
 
 	rm, err = k.Run(path)
 	if err != nil {
+		if pp, ok := fSys.(fsPreprocessor); ok && pp.Err() != nil {
+			err = pp.Err()
+		}
 		return nil, fmt.Errorf("Kustomizer Run for path '%s' failed: %s", path, err)
 	}
 
 	return rm, nil
 }
 
-func setResourcesFromKustomize(d *schema.ResourceData, path string) error {
-	fSys := filesys.MakeFsOnDisk()
-	return setResourcesFromKustomizeUsingFs(d, fSys, path)
+// makeBuildFileSys wraps fSys to preprocess kustomizations
+// according to the provider configuration, and to substitute
+// variables unless vs is nil
+func makeBuildFileSys(fSys filesys.FileSystem, m interface{}, vs *variableSubstitution) filesys.FileSystem {
+	config := m.(*Config)
+
+	fSys = makeFsSopsDecrypter(fSys, config.SopsPath, config.SopsAgeKey)
+	if vs != nil {
+		fSys = makeFsVariableSubstituter(fSys, *vs)
+	}
+
+	return makeFsPreprocessor(
+		fSys,
+		krmExecPreprocessor(config.ExecFunctionAllowlist, config.ExecFunctionEnv),
+		helmPreprocessor(config.HelmPath),
+	)
 }
 
-func setResourcesFromKustomizeUsingFs(d *schema.ResourceData, fSys filesys.FileSystem, path string) error {
-	rm, err := runKustomizeBuildWithFileSys(fSys, path)
+// setResourcesFromKustomizeUsingFs builds path and applies the
+// transformers to the result, before setting the resources
+func setResourcesFromKustomizeUsingFs(d *schema.ResourceData, fSys filesys.FileSystem, path string, m interface{}, transformers ...resmap.Transformer) error {
+	rm, err := runKustomizeBuildWithFileSys(makeBuildFileSys(fSys, m, expandVariableSubstitution(d)), path)
+	if err != nil {
+		return fmt.Errorf("kustomizationBuild: %s", err)
+	}
+
+	err = applyTransformers(rm, transformers)
 	if err != nil {
 		return fmt.Errorf("kustomizationBuild: %s", err)
 	}
 
 	d.Set("ids", flattenKustomizationIDs(rm))
 
-	resources, err := flattenKustomizationResources(rm)
+	resources, sensitiveResources, err := flattenKustomizationResources(rm)
 	if err != nil {
 		return fmt.Errorf("kustomizationBuild: %s", err)
 	}
 	d.Set("manifests", resources)
+	d.Set("sensitive_manifests", sensitiveResources)
+
+	hashes, err := flattenKustomizationHashes(rm)
+	if err != nil {
+		return fmt.Errorf("kustomizationBuild: %s", err)
+	}
+	d.Set("hashes", hashes)
 
 	id, err := getIDFromResources(rm)
 	if err != nil {
@@ -97,5 +189,90 @@ func setResourcesFromKustomizeUsingFs(d *schema.ResourceData, fSys filesys.FileS
 
 func kustomizationBuild(d *schema.ResourceData, m interface{}) error {
 	path := d.Get("path").(string)
-	return  setResourcesFromKustomize(d, path)
+
+	transformers, err := makeOverrideTransformers(
+		expandImages(d.Get("images").([]interface{})),
+		expandReplicas(d.Get("replicas").([]interface{})))
+	if err != nil {
+		return fmt.Errorf("kustomizationBuild: %s", err)
+	}
+
+	var fSys filesys.FileSystem = filesys.MakeFsOnDisk()
+	if files, ok := d.GetOk("files"); ok {
+		fSys, err = makeFsInMemoryFromFiles(files.(map[string]interface{}))
+		if err != nil {
+			return fmt.Errorf("kustomizationBuild: %s", err)
+		}
+		path = filepath.Join(inMemoryRootDir, path)
+	}
+
+	fSys, path, err = layerOverrideKustomization(fSys, path, expandKustomizationOverrides(d))
+	if err != nil {
+		return fmt.Errorf("kustomizationBuild: %s", err)
+	}
+
+	return setResourcesFromKustomizeUsingFs(d, fSys, path, m, transformers...)
+}
+
+// layerOverrideKustomization layers a synthetic kustomization with the
+// overrides, using path as its only resource, on top of fSys. It is
+// added in a virtual root that only exists in an in-memory overlay.
+// Without overrides, fSys and path are returned unchanged.
+func layerOverrideKustomization(fSys filesys.FileSystem, path string, overrides types.Kustomization) (filesys.FileSystem, string, error) {
+	if reflect.DeepEqual(overrides, types.Kustomization{}) {
+		return fSys, path, nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// kustomize detects roots inside other roots as cycles,
+	// so the virtual root has to be a sibling of path
+	rootDir, err := makeVirtualRootDir(filepath.Dir(absPath), ".kustomization_overrides")
+	if err != nil {
+		return nil, "", err
+	}
+	resource, err := filepath.Rel(rootDir, absPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	overlay := makeFsOverlayOn(fSys, rootDir)
+	overrides.Resources = []string{resource}
+
+	data, err := yaml.Marshal(overrides)
+	if err != nil {
+		return nil, "", err
+	}
+	err = overlay.AddOverlayFile("kustomization.yaml", data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return overlay, rootDir, nil
+}
+
+// inMemoryRootDir is not "/", to leave room for virtual roots next to it
+const inMemoryRootDir = "/files"
+
+// makeFsInMemoryFromFiles makes an in-memory filesystem containing
+// files, keyed by their path relative to inMemoryRootDir
+func makeFsInMemoryFromFiles(files map[string]interface{}) (filesys.FileSystem, error) {
+	fSys := filesys.MakeFsInMemory()
+
+	for name, content := range files {
+		cleaned := filepath.Clean(name)
+		if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return nil, fmt.Errorf("file path '%s' must be relative and inside the root", name)
+		}
+
+		err := fSys.WriteFile(filepath.Join(inMemoryRootDir, cleaned), []byte(content.(string)))
+		if err != nil {
+			return nil, fmt.Errorf("adding file '%s' failed: %s", name, err)
+		}
+	}
+
+	return fSys, nil
 }
@@ -0,0 +1,172 @@
+package kustomize
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/konfig"
+)
+
+// run 'go test ./kustomize -run TestKustomizationGolden -update'
+// to regenerate the golden files after intended changes
+var updateGolden = flag.Bool("update", false, "update the golden files of TestKustomizationGolden")
+
+const goldenFixturesDir = "../test_kustomizations"
+
+const goldenFilesDir = "testdata/golden"
+
+// goldenFakeHelm renders a ConfigMap named after the release, that
+// records the chart and values, but no machine specific paths
+const goldenFakeHelm = `#!/bin/sh
+cat <<MANIFEST
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: $2
+data:
+  chart: $(basename $3)
+  values: |
+$(sed 's/^/    /')
+MANIFEST
+`
+
+// kustomizationGolden is the expected build output of a fixture
+type kustomizationGolden struct {
+	ID                 string                 `json:"id"`
+	IDs                []string               `json:"ids"`
+	Manifests          map[string]interface{} `json:"manifests"`
+	SensitiveManifests map[string]interface{} `json:"sensitive_manifests"`
+}
+
+// goldenFixtures returns the paths of all kustomizations in dir
+func goldenFixtures(dir string) (fixtures []string, err error) {
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+
+		for _, name := range konfig.RecognizedKustomizationFileNames() {
+			if _, err := os.Stat(filepath.Join(path, name)); err == nil {
+				fixtures = append(fixtures, path)
+				break
+			}
+		}
+
+		return nil
+	})
+
+	return fixtures, err
+}
+
+// canonicalManifests decodes the JSON manifests, so they are
+// written with sorted keys and consistent indentation
+func canonicalManifests(manifests map[string]interface{}) (map[string]interface{}, error) {
+	canonical := make(map[string]interface{})
+	for id, m := range manifests {
+		var obj interface{}
+		err := json.Unmarshal([]byte(m.(string)), &obj)
+		if err != nil {
+			return nil, err
+		}
+		canonical[id] = obj
+	}
+
+	return canonical, nil
+}
+
+func buildKustomizationGolden(t *testing.T, path string, m interface{}) ([]byte, error) {
+	d := schema.TestResourceDataRaw(t, dataSourceKustomization().Schema, map[string]interface{}{
+		"path": path,
+	})
+
+	err := setResourcesFromKustomizeUsingFs(d, filesys.MakeFsOnDisk(), path, m)
+	if err != nil {
+		return nil, err
+	}
+
+	var g kustomizationGolden
+	g.ID = d.Id()
+
+	for _, id := range d.Get("ids").(*schema.Set).List() {
+		g.IDs = append(g.IDs, id.(string))
+	}
+	sort.Strings(g.IDs)
+
+	g.Manifests, err = canonicalManifests(d.Get("manifests").(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	g.SensitiveManifests, err = canonicalManifests(d.Get("sensitive_manifests").(map[string]interface{}))
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out, '\n'), nil
+}
+
+func TestKustomizationGolden(t *testing.T) {
+	// the helm fixture is rendered by a fake helm binary
+	dir, err := ioutil.TempDir("", "kustomize-golden")
+	if err != nil {
+		t.Fatalf("TestKustomizationGolden: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	helmPath := filepath.Join(dir, "helm")
+	err = ioutil.WriteFile(helmPath, []byte(goldenFakeHelm), 0755)
+	if err != nil {
+		t.Fatalf("TestKustomizationGolden: %s", err)
+	}
+
+	m := &Config{HelmPath: helmPath}
+
+	fixtures, err := goldenFixtures(goldenFixturesDir)
+	if err != nil {
+		t.Fatalf("TestKustomizationGolden: %s", err)
+	}
+
+	for _, fixture := range fixtures {
+		rel, _ := filepath.Rel(goldenFixturesDir, fixture)
+		goldenPath := filepath.Join(goldenFilesDir, rel+".json")
+
+		got, err := buildKustomizationGolden(t, fixture, m)
+		if err != nil {
+			t.Errorf("TestKustomizationGolden: %s: %s", rel, err)
+			continue
+		}
+
+		if *updateGolden {
+			err = os.MkdirAll(filepath.Dir(goldenPath), 0755)
+			if err == nil {
+				err = ioutil.WriteFile(goldenPath, got, 0644)
+			}
+			if err != nil {
+				t.Fatalf("TestKustomizationGolden: %s: %s", rel, err)
+			}
+			continue
+		}
+
+		want, err := ioutil.ReadFile(goldenPath)
+		if err != nil {
+			t.Errorf("TestKustomizationGolden: %s: %s, run with -update to create it", rel, err)
+			continue
+		}
+
+		if !bytes.Equal(got, want) {
+			t.Errorf("TestKustomizationGolden: %s: build output differs from %s, run with -update if the change is intended, got:\n%s", rel, goldenPath, got)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package kustomize
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	"gopkg.in/yaml.v2"
+)
+
+func dataSourceKustomizationOverlay() *schema.Resource {
+	return &schema.Resource{
+		Read: kustomizationOverlayBuild,
+
+		Schema: withVariableSubstitutionSchema(map[string]*schema.Schema{
+			"base_dir": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Directory relative paths are resolved against. Defaults to the current working directory, set to path.module to resolve paths relative to the module.",
+			},
+			"resources": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"namespace": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"name_prefix": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"name_suffix": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"common_labels": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"common_annotations": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"images":   imagesSchema(),
+			"replicas": replicasSchema(),
+			"patches": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"path": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"patch": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"target": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"group": {
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+									"version": {
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+									"kind": {
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+									"name": {
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+									"namespace": {
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+									"label_selector": {
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+									"annotation_selector": {
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"config_map_generator": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: generatorArgsSchema(),
+				},
+			},
+			"secret_generator": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: secretGeneratorArgsSchema(),
+				},
+			},
+			"generator_options": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: generatorOptionsSchema(),
+				},
+			},
+			"ids": {
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"manifests": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"hashes": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Canonical hash of each resource, that only changes if the resource does.",
+			},
+			"sensitive_manifests": {
+				Type:      schema.TypeMap,
+				Computed:  true,
+				Sensitive: true,
+				Elem:      &schema.Schema{Type: schema.TypeString},
+			},
+		}),
+	}
+}
+
+func imagesSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"new_name": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"new_tag": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"digest": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+			},
+		},
+	}
+}
+
+func replicasSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"count": {
+					Type:         schema.TypeInt,
+					Required:     true,
+					ValidateFunc: validation.IntAtLeast(0),
+				},
+			},
+		},
+	}
+}
+
+func generatorArgsSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"name": {
+			Type:     schema.TypeString,
+			Required: true,
+		},
+		"namespace": {
+			Type:     schema.TypeString,
+			Optional: true,
+		},
+		"behavior": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.StringInSlice([]string{"create", "replace", "merge"}, false),
+		},
+		"literals": {
+			Type:     schema.TypeList,
+			Optional: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+		"files": {
+			Type:     schema.TypeList,
+			Optional: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+		"envs": {
+			Type:     schema.TypeList,
+			Optional: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+		"options": {
+			Type:     schema.TypeList,
+			Optional: true,
+			MaxItems: 1,
+			Elem: &schema.Resource{
+				Schema: generatorOptionsSchema(),
+			},
+		},
+	}
+}
+
+func secretGeneratorArgsSchema() map[string]*schema.Schema {
+	s := generatorArgsSchema()
+	s["type"] = &schema.Schema{
+		Type:     schema.TypeString,
+		Optional: true,
+	}
+
+	return s
+}
+
+func generatorOptionsSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"labels": {
+			Type:     schema.TypeMap,
+			Optional: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+		"annotations": {
+			Type:     schema.TypeMap,
+			Optional: true,
+			Elem:     &schema.Schema{Type: schema.TypeString},
+		},
+		"disable_name_suffix_hash": {
+			Type:     schema.TypeBool,
+			Optional: true,
+		},
+	}
+}
+
+func kustomizationOverlayBuild(d *schema.ResourceData, m interface{}) error {
+	overlay, err := MakefsOverlay(d.Get("base_dir").(string))
+	if err != nil {
+		return err
+	}
+
+	err = validateOverlayPatches(d.Get("patches").([]interface{}))
+	if err != nil {
+		return err
+	}
+
+	k := expandKustomizationOverlay(d)
+
+	// round trip through yaml to rebase the paths
+	data, err := yaml.Marshal(k)
+	if err != nil {
+		return err
+	}
+	kustomization, err := fromYaml(string(data))
+	if err != nil {
+		return err
+	}
+
+	return buildKustomizationInOverlay(d, overlay, kustomization, m)
+}
+
+// validateOverlayPatches checks that every patch sets exactly one of
+// path and patch, the SDK can't validate this inside list elements
+func validateOverlayPatches(patches []interface{}) error {
+	for ix, v := range patches {
+		p, _ := v.(map[string]interface{})
+		path, _ := p["path"].(string)
+		patch, _ := p["patch"].(string)
+
+		if (path == "") == (patch == "") {
+			return fmt.Errorf("patches.%d: exactly one of path or patch must be set", ix)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package kustomize
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+)
+
+func TestAccDataSourceKustomizationOverlay_basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		//PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceKustomizationOverlayConfig_basic(),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.kustomization_overlay.test", "ids.#", "6"),
+					resource.TestCheckResourceAttr("data.kustomization_overlay.test", "manifests.%", "5"),
+					resource.TestCheckResourceAttr("data.kustomization_overlay.test", "sensitive_manifests.%", "1"),
+					testAccCheckManifestContains("data.kustomization_overlay.test", "apps_v1_Deployment|test-basic|pre-test-suf", "\"replicas\":3"),
+					testAccCheckManifestContains("data.kustomization_overlay.test", "apps_v1_Deployment|test-basic|pre-test-suf", "\"image\":\"nginx:1.19\""),
+					testAccCheckManifestContains("data.kustomization_overlay.test", "apps_v1_Deployment|test-basic|pre-test-suf", "\"team\":\"platform\""),
+					testAccCheckManifestContains("data.kustomization_overlay.test", "apps_v1_Deployment|test-basic|pre-test-suf", "\"owner\":\"ops\""),
+					testAccCheckManifestContains("data.kustomization_overlay.test", "apps_v1_Deployment|test-basic|pre-test-suf", "\"runAsNonRoot\":true"),
+					testAccCheckManifestContains("data.kustomization_overlay.test", "~G_v1_ConfigMap|test-basic|pre-test-suf", "\"color\":\"blue\""),
+					testAccCheckManifestContains("data.kustomization_overlay.test", "~G_v1_ConfigMap|test-basic|pre-test-suf", "\"generated\":\"true\""),
+				),
+			},
+		},
+	})
+}
+
+func testAccDataSourceKustomizationOverlayConfig_basic() string {
+	return `
+data "kustomization_overlay" "test" {
+	base_dir = "../test_kustomizations/template"
+
+	resources = [
+		"namespace.yaml",
+		"_example_app",
+	]
+
+	namespace   = "test-basic"
+	name_prefix = "pre-"
+	name_suffix = "-suf"
+
+	common_labels = {
+		team = "platform"
+	}
+
+	common_annotations = {
+		owner = "ops"
+	}
+
+	images {
+		name    = "nginx"
+		new_tag = "1.19"
+	}
+
+	replicas {
+		name  = "test"
+		count = 3
+	}
+
+	patches {
+		patch = <<-EOF
+			- op: add
+			  path: /spec/template/spec/securityContext
+			  value:
+			    runAsNonRoot: true
+		EOF
+
+		target {
+			group   = "apps"
+			version = "v1"
+			kind    = "Deployment"
+			name    = "test"
+		}
+	}
+
+	config_map_generator {
+		name     = "test"
+		literals = ["color=blue"]
+	}
+
+	secret_generator {
+		name     = "test"
+		literals = ["password=secret"]
+		type     = "Opaque"
+	}
+
+	generator_options {
+		disable_name_suffix_hash = true
+		labels = {
+			generated = "true"
+		}
+	}
+}
+`
+}
+
+func TestAccDataSourceKustomizationOverlay_validate(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		//PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: `
+data "kustomization_overlay" "test" {
+	name_prefx = "pre-"
+}
+`,
+				ExpectError: regexp.MustCompile(`An argument named "name_prefx" is not expected here`),
+			},
+			{
+				Config: `
+data "kustomization_overlay" "test" {
+	config_map_generator {
+		name     = "test"
+		behavior = "replce"
+	}
+}
+`,
+				ExpectError: regexp.MustCompile(`expected config_map_generator.0.behavior to be one of \[create replace merge\]`),
+			},
+			{
+				Config: `
+data "kustomization_overlay" "test" {
+	base_dir = "../test_kustomizations/template"
+
+	resources = ["namespace.yaml"]
+
+	patches {
+	}
+}
+`,
+				ExpectError: regexp.MustCompile(`patches.0: exactly one of path or patch must be set`),
+			},
+			{
+				Config: `
+data "kustomization_overlay" "test" {
+	base_dir = "../test_kustomizations/template"
+
+	resources = ["namespace.yaml"]
+
+	patches {
+		path  = "patches/resources.yaml"
+		patch = "- op: remove\n  path: /metadata/labels"
+	}
+}
+`,
+				ExpectError: regexp.MustCompile(`patches.0: exactly one of path or patch must be set`),
+			},
+		},
+	})
+}
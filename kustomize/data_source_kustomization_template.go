@@ -2,24 +2,62 @@ package kustomize
 
 import (
 	"fmt"
+	"regexp"
+
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 )
 
-var fileArrayFields = [...]string {
-	"configurations",
-	"patchesStrategicMerge",
-	"resources",
+// pathFields lists the keys leading to kustomization fields that hold
+// paths. The key "[]" iterates a list. Every path can also be given
+// as inline content, yaml string or map, that is added to the overlay.
+var pathFields = [...][]string{
+	{"resources", "[]"},
+	{"bases", "[]"},
+	{"crds", "[]"},
+	{"configurations", "[]"},
+	{"generators", "[]"},
+	{"transformers", "[]"},
+	{"patchesStrategicMerge", "[]"},
+	{"patchesJson6902", "[]", "path"},
+	{"patches", "[]", "path"},
+	{"configMapGenerator", "[]", "files", "[]"},
+	{"configMapGenerator", "[]", "envs", "[]"},
+	{"secretGenerator", "[]", "files", "[]"},
+	{"secretGenerator", "[]", "envs", "[]"},
+	{"helmGlobals", "chartHome"},
+	{"helmCharts", "[]", "valuesFile"},
+}
+
+// resourceDocumentFields take resources, inline multi document
+// streams and lists are expanded into one document per resource
+var resourceDocumentFields = map[string]bool{
+	"resources":             true,
+	"patchesStrategicMerge": true,
 }
 
+// generatorFileKey matches the optional key prefix of generator files
+var generatorFileKey = regexp.MustCompile(`^[-._a-zA-Z0-9]+=`)
+
 func dataSourceKustomizationTemplate() *schema.Resource {
 	return &schema.Resource{
 		Read: kustomizationTemplateBuild,
-		Schema: map[string]*schema.Schema{
+		Schema: withVariableSubstitutionSchema(map[string]*schema.Schema{
 			"kustomization": {
 				Type:        schema.TypeString,
 				Required:    true,
 				Description: "Kustomization yaml as map.  See https://kubectl.docs.kubernetes.io/pages/reference/kustomize.html",
 			},
+			"base_dir": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Directory relative paths in the kustomization are resolved against. Defaults to the current working directory, set to path.module to resolve paths relative to the module.",
+			},
+			"detect_inline_content": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Treat strings that can not be loaded as paths as inline content, like earlier versions did. Prefer the explicit {content = ...} and {manifest = {...}} forms.",
+			},
 			"ids": {
 				Type:     schema.TypeSet,
 				Computed: true,
@@ -30,24 +68,38 @@ func dataSourceKustomizationTemplate() *schema.Resource {
 				Computed: true,
 				Elem:     &schema.Schema{Type: schema.TypeString},
 			},
-		},
+			"hashes": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Canonical hash of each resource, that only changes if the resource does.",
+			},
+			"sensitive_manifests": {
+				Type:      schema.TypeMap,
+				Computed:  true,
+				Sensitive: true,
+				Elem:      &schema.Schema{Type: schema.TypeString},
+			},
+		}),
 	}
 }
 
 func kustomizationTemplateBuild(d *schema.ResourceData, m interface{}) error {
-	overlay, err := MakefsOverlay(); if err != nil {
+	overlay, err := MakefsOverlay(d.Get("base_dir").(string)); if err != nil {
 		return err
 	}
+	overlay.detectInlineContent = d.Get("detect_inline_content").(bool)
 	kustomization, err := fromYaml(d.Get("kustomization").(string)); if err != nil {
 		return  err
 	}
 
-	for _, field := range fileArrayFields {
-		err := addFileListToKustomize(overlay, kustomization, field); if err != nil {
-			return err
-		}
-	}
-	err = addPatchesjson6902ToKustomize(overlay, kustomization); if err != nil {
+	return buildKustomizationInOverlay(d, overlay, kustomization, m)
+}
+
+// buildKustomizationInOverlay rebases the paths of the kustomization,
+// adds it to the overlay's virtual root and builds it
+func buildKustomizationInOverlay(d *schema.ResourceData, overlay FsOverlay, kustomization map[interface{}]interface{}, m interface{}) error {
+	err := rebaseKustomizationPaths(overlay, kustomization); if err != nil {
 		return err
 	}
 
@@ -57,60 +109,116 @@ func kustomizationTemplateBuild(d *schema.ResourceData, m interface{}) error {
 	err = overlay.AddOverlayFile("kustomization.yaml", kustomizationYaml); if err != nil {
 		return err
 	}
-	err = setResourcesFromKustomizeUsingFs(d, overlay, overlay.rootDir)
+	err = setResourcesFromKustomizeUsingFs(d, overlay, overlay.rootDir, m)
 
 	return err
 }
 
-func addFileListToKustomize(overlay FsOverlay, kustomization map[interface{}]interface{}, key string) error {
-	var specs []interface{}
-	value, ok := kustomization[key]; if !ok {
-		return nil
-	} else {
-		specs = value.([]interface{})
-	}
-
-	if len(specs) == 0 {
-		delete(kustomization, key)
-		return nil
-	}
-
-	names, err := overlay.AddOverlayFiles(key, specs); if err != nil {
-		return err
+// rebaseKustomizationPaths resolves the paths in pathFields against
+// the overlay's base directory, and adds inline content to the overlay
+func rebaseKustomizationPaths(overlay FsOverlay, kustomization map[interface{}]interface{}) error {
+	count := 0
+	for _, keys := range pathFields {
+		prefix := keys[0]
+		isGeneratorFile := len(keys) == 4 && keys[2] == "files"
+
+		_, err := mapPathField(kustomization, keys, "", func(loc string, value interface{}) (interface{}, error) {
+			// generator files can be prefixed with a key
+			key, value, err := generatorFileKeyAndSource(value, isGeneratorFile)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", loc, err)
+			}
+
+			name := fmt.Sprintf("%s_%d", prefix, count)
+			count++
+
+			add := overlay.AddOverlayPathOrContent
+			if resourceDocumentFields[prefix] {
+				add = overlay.AddOverlayResources
+			}
+
+			resolved, err := add(name, value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", loc, err)
+			}
+			return key + resolved, nil
+		})
+		if err != nil {
+			return err
+		}
 	}
 
-	kustomization[key] = names
 	return nil
 }
 
-func addPatchesjson6902ToKustomize(overlay FsOverlay, kustomization map[interface{}]interface{}) error {
-	key := "patchesJson6902"
-	field := "path"
+// generatorFileKeyAndSource splits the key from generator files,
+// given as key=path or as typed source with a key attribute
+func generatorFileKeyAndSource(value interface{}, isGeneratorFile bool) (key string, source interface{}, err error) {
+	switch v := value.(type) {
+	case string:
+		if isGeneratorFile {
+			if loc := generatorFileKey.FindStringIndex(v); loc != nil {
+				return v[:loc[1]], v[loc[1]:], nil
+			}
+		}
+	case map[interface{}]interface{}:
+		k, ok := v["key"]
+		if !ok {
+			break
+		}
+		if !isGeneratorFile {
+			return "", nil, fmt.Errorf("key is only supported for the files of generators")
+		}
 
-	var specs []map[string]interface{}
-	value, ok := kustomization[key]; if !ok {
-		return nil
-	} else {
-		specs = value.([]map[string]interface{})
+		source := make(map[interface{}]interface{})
+		for sk, sv := range v {
+			if sk != "key" {
+				source[sk] = sv
+			}
+		}
+		return fmt.Sprintf("%v=", k), source, nil
 	}
 
-	if len(specs) == 0 {
-		delete(kustomization, key)
-		return nil
-	}
+	return "", value, nil
+}
 
-	paths := make([]interface{}, len(specs))
-	for ix, patch := range specs {
-		paths[ix], ok = patch[field]; if !ok {
-			return fmt.Errorf("%s does not contain %s (%v)", key, field, patch)
+// mapPathField calls fn for every value found following keys and
+// replaces it with the result, loc is the location of value
+func mapPathField(value interface{}, keys []string, loc string, fn func(string, interface{}) (interface{}, error)) (interface{}, error) {
+	if len(keys) == 0 {
+		if value == nil {
+			return value, nil
 		}
+		return fn(loc, value)
 	}
 
-	names, err := overlay.AddOverlayFiles(key, paths); if err != nil {
-		return err
-	}
-	for ix, patch := range specs {
-		patch[field] = names[ix]
+	switch v := value.(type) {
+	case []interface{}:
+		if keys[0] != "[]" {
+			return value, nil
+		}
+		for ix := range v {
+			mapped, err := mapPathField(v[ix], keys[1:], fmt.Sprintf("%s[%d]", loc, ix), fn)
+			if err != nil {
+				return nil, err
+			}
+			v[ix] = mapped
+		}
+	case map[interface{}]interface{}:
+		child, ok := v[keys[0]]
+		if !ok {
+			return value, nil
+		}
+		childLoc := keys[0]
+		if loc != "" {
+			childLoc = loc + "." + keys[0]
+		}
+		mapped, err := mapPathField(child, keys[1:], childLoc, fn)
+		if err != nil {
+			return nil, err
+		}
+		v[keys[0]] = mapped
 	}
-	return nil
+
+	return value, nil
 }
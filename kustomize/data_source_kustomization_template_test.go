@@ -2,9 +2,12 @@ package kustomize
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
 )
 
 var resourceTemplate = `
@@ -57,9 +60,269 @@ func testAccDataSourceKustomizationTemplateConfig_basic(kustomization map[interf
 	kustomizationYaml, _ := toYaml(kustomization)
 	return fmt.Sprintf(`
 data "kustomization_template" "test" {
+	detect_inline_content = true
+
 	kustomization = <<-EOF
 %s
 EOF
 }
 `, string(kustomizationYaml))
 }
+
+func TestAccDataSourceKustomizationTemplate_baseDir(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		//PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceKustomizationTemplateConfig_baseDir(),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.kustomization_template.test", "ids.#", "5"),
+					resource.TestCheckResourceAttr("data.kustomization_template.test", "manifests.%", "5"),
+					resource.TestCheckResourceAttr("data.kustomization_template.other", "ids.#", "1"),
+					resource.TestCheckResourceAttr("data.kustomization_template.other", "manifests.%", "1"),
+				),
+			},
+		},
+	})
+}
+
+func testAccDataSourceKustomizationTemplateConfig_baseDir() string {
+	return `
+data "kustomization_template" "test" {
+	base_dir = "../test_kustomizations/template"
+
+	kustomization = <<-EOF
+		resources:
+		- namespace.yaml
+		- _example_app
+		configMapGenerator:
+		- name: test
+		  namespace: test-basic
+		  files:
+		  - namespace.yaml
+	EOF
+}
+
+data "kustomization_template" "other" {
+	base_dir = "../test_kustomizations/template"
+
+	kustomization = <<-EOF
+		resources:
+		- namespace.yaml
+	EOF
+}
+`
+}
+
+func TestAccDataSourceKustomizationTemplate_inlinePaths(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		//PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceKustomizationTemplateConfig_inlinePaths(),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.kustomization_template.test", "ids.#", "4"),
+					resource.TestCheckResourceAttr("data.kustomization_template.test", "manifests.%", "3"),
+					resource.TestCheckResourceAttr("data.kustomization_template.test", "sensitive_manifests.%", "1"),
+					testAccCheckManifestContains("data.kustomization_template.test", "apps_v1_Deployment|test-basic|test", "\"replicas\":3"),
+					testAccCheckManifestContains("data.kustomization_template.test", "apps_v1_Deployment|test-basic|test", "\"image\":\"nginx:1.19\""),
+					testAccCheckManifestContains("data.kustomization_template.test", "~G_v1_ConfigMap|test-basic|test", "\"app.properties\":\"color: blue\""),
+					testAccCheckManifestContains("data.kustomization_template.test", "~G_v1_ConfigMap|test-basic|test", "\"LOG_LEVEL\":\"debug\""),
+				),
+			},
+		},
+	})
+}
+
+func testAccCheckManifestContains(n string, id string, want string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+
+		manifest, ok := rs.Primary.Attributes[fmt.Sprintf("manifests.%s", id)]
+		if !ok {
+			return fmt.Errorf("%s: no manifest for %s", n, id)
+		}
+		if !strings.Contains(manifest, want) {
+			return fmt.Errorf("%s: manifest for %s does not contain %s: %s", n, id, want, manifest)
+		}
+
+		return nil
+	}
+}
+
+func testAccDataSourceKustomizationTemplateConfig_inlinePaths() string {
+	return `
+data "kustomization_template" "test" {
+	base_dir = "../test_kustomizations/template"
+
+	kustomization = yamlencode({
+		namespace = "test-basic"
+		generatorOptions = {
+			disableNameSuffixHash = true
+		}
+		resources = [
+			{ path = "namespace.yaml" },
+			{
+				apiVersion = "apps/v1"
+				kind       = "Deployment"
+				metadata = {
+					name = "test"
+				}
+				spec = {
+					replicas = 1
+					template = {
+						spec = {
+							containers = [{ name = "nginx", image = "nginx" }]
+						}
+					}
+				}
+			},
+		]
+		patches = [{
+			path = { content = <<-EOT
+				apiVersion: apps/v1
+				kind: Deployment
+				metadata:
+				  name: test
+				spec:
+				  replicas: 3
+			EOT
+			}
+		}]
+		patchesJson6902 = [{
+			target = {
+				group   = "apps"
+				version = "v1"
+				kind    = "Deployment"
+				name    = "test"
+			}
+			path = {
+				manifest = [{
+					op    = "replace"
+					path  = "/spec/template/spec/containers/0/image"
+					value = "nginx:1.19"
+				}]
+			}
+		}]
+		configMapGenerator = [{
+			name  = "test"
+			files = [{ key = "app.properties", content = "color: blue" }]
+			envs  = [{ content = "LOG_LEVEL=debug\n" }]
+		}]
+		secretGenerator = [{
+			name = "test"
+			envs = [{ content = "PASSWORD=secret\n" }]
+		}]
+	})
+}
+`
+}
+
+func TestAccDataSourceKustomizationTemplate_inlinePathsErrors(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		//PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccDataSourceKustomizationTemplateConfig_inlinePathsErrors(`resources = ["namespace.yaml", "namespce.yaml"]`),
+				ExpectError: regexp.MustCompile(`resources\[1\]: 'namespce.yaml' is not a file or directory`),
+			},
+			{
+				Config:      testAccDataSourceKustomizationTemplateConfig_inlinePathsErrors(`patches = [{ path = { content = "a", manifest = {} } }]`),
+				ExpectError: regexp.MustCompile(`patches\[0\].path: typed source must have exactly one of path, content, manifest`),
+			},
+			{
+				Config:      testAccDataSourceKustomizationTemplateConfig_inlinePathsErrors(`configMapGenerator = [{ name = "test", envs = [{ key = "a", content = "A=b" }] }]`),
+				ExpectError: regexp.MustCompile(`configMapGenerator\[0\].envs\[0\]: key is only supported for the files of generators`),
+			},
+		},
+	})
+}
+
+func testAccDataSourceKustomizationTemplateConfig_inlinePathsErrors(kustomization string) string {
+	return fmt.Sprintf(`
+data "kustomization_template" "test" {
+	base_dir = "../test_kustomizations/template"
+
+	kustomization = yamlencode({
+		%s
+	})
+}
+`, kustomization)
+}
+
+func TestAccDataSourceKustomizationTemplate_multiDocument(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		//PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceKustomizationTemplateConfig_multiDocument(`
+					apiVersion: v1
+					kind: Namespace
+					metadata:
+					  name: test-multi
+					---
+					apiVersion: v1
+					kind: ConfigMapList
+					items:
+					- apiVersion: v1
+					  kind: ConfigMap
+					  metadata:
+					    name: a
+					    namespace: test-multi
+					- apiVersion: v1
+					  kind: ConfigMap
+					  metadata:
+					    name: b
+					    namespace: test-multi
+				`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.kustomization_template.test", "ids.#", "4"),
+					resource.TestCheckResourceAttr("data.kustomization_template.test", "manifests.%", "4"),
+				),
+			},
+			{
+				Config: testAccDataSourceKustomizationTemplateConfig_multiDocument(`
+					apiVersion: v1
+					kind: Namespace
+					metadata:
+					  name: test-multi
+					---
+					- not a resource
+				`),
+				ExpectError: regexp.MustCompile(`resources\[1\]: document 1: item 0: expected a resource or a list of resources`),
+			},
+		},
+	})
+}
+
+func testAccDataSourceKustomizationTemplateConfig_multiDocument(content string) string {
+	return fmt.Sprintf(`
+data "kustomization_template" "test" {
+	base_dir = "../test_kustomizations/template"
+
+	kustomization = yamlencode({
+		resources = [
+			{ manifest = [{
+				apiVersion = "v1"
+				kind       = "ConfigMap"
+				metadata = {
+					name      = "c"
+					namespace = "test-multi"
+				}
+			}] },
+			{ content = <<-EOF
+%s
+EOF
+			},
+		]
+	})
+}
+`, strings.TrimSpace(strings.ReplaceAll(content, "\n\t\t\t\t\t", "\n")))
+}
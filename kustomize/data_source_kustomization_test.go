@@ -2,6 +2,8 @@ package kustomize
 
 import (
 	"fmt"
+	"path/filepath"
+	"regexp"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
@@ -21,6 +23,7 @@ func TestAccDataSourceKustomization_basic(t *testing.T) {
 					resource.TestCheckResourceAttr("data.kustomization.test", "path", "../test_kustomizations/basic/initial"),
 					resource.TestCheckResourceAttr("data.kustomization.test", "ids.#", "4"),
 					resource.TestCheckResourceAttr("data.kustomization.test", "manifests.%", "4"),
+					resource.TestCheckResourceAttr("data.kustomization.test", "hashes.%", "4"),
 				),
 			},
 		},
@@ -34,3 +37,254 @@ data "kustomization" "test" {
 }
 `, path)
 }
+
+func TestAccDataSourceKustomization_secret(t *testing.T) {
+
+	resource.Test(t, resource.TestCase{
+		//PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceKustomizationConfig_basic("../test_kustomizations/secret"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("data.kustomization.test", "id"),
+					resource.TestCheckResourceAttr("data.kustomization.test", "ids.#", "2"),
+					resource.TestCheckResourceAttr("data.kustomization.test", "manifests.%", "1"),
+					resource.TestCheckResourceAttr("data.kustomization.test", "sensitive_manifests.%", "1"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDataSourceKustomization_files(t *testing.T) {
+
+	resource.Test(t, resource.TestCase{
+		//PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceKustomizationConfig_files(),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttrSet("data.kustomization.test", "id"),
+					resource.TestCheckResourceAttr("data.kustomization.test", "path", "overlay"),
+					resource.TestCheckResourceAttr("data.kustomization.test", "ids.#", "2"),
+					resource.TestCheckResourceAttr("data.kustomization.test", "manifests.%", "2"),
+				),
+			},
+		},
+	})
+}
+
+func testAccDataSourceKustomizationConfig_files() string {
+	return `
+data "kustomization" "test" {
+	path = "overlay"
+
+	files = {
+		"base/kustomization.yaml" = <<-EOT
+			resources:
+			- namespace.yaml
+			configMapGenerator:
+			- name: test
+			  literals:
+			  - key=value
+		EOT
+
+		"base/namespace.yaml" = <<-EOT
+			apiVersion: v1
+			kind: Namespace
+			metadata:
+			  name: test-files
+		EOT
+
+		"overlay/kustomization.yaml" = <<-EOT
+			namespace: test-files
+			resources:
+			- ../base
+		EOT
+	}
+}
+`
+}
+
+func TestMakeFsInMemoryFromFiles(t *testing.T) {
+	for _, name := range []string{"../outside.yaml", "/absolute.yaml", "nested/../../outside.yaml"} {
+		_, err := makeFsInMemoryFromFiles(map[string]interface{}{name: ""})
+		if err == nil {
+			t.Errorf("TestMakeFsInMemoryFromFiles: expected error for path outside the root: %s", name)
+		}
+	}
+
+	fSys, err := makeFsInMemoryFromFiles(map[string]interface{}{"nested/./file.yaml": "content"})
+	if err != nil {
+		t.Errorf("TestMakeFsInMemoryFromFiles: %s", err)
+	}
+	if !fSys.Exists(filepath.Join(inMemoryRootDir, "nested/file.yaml")) {
+		t.Errorf("TestMakeFsInMemoryFromFiles: file missing from in-memory filesystem")
+	}
+}
+
+func TestAccDataSourceKustomization_variables(t *testing.T) {
+
+	resource.Test(t, resource.TestCase{
+		//PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceKustomizationConfig_variables(),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.kustomization.test", "ids.#", "2"),
+					testAccCheckManifestContains("data.kustomization.test", "~G_v1_ConfigMap|test-vars|test", "\"host\":\"prod.example.com\""),
+					testAccCheckManifestContains("data.kustomization.test", "~G_v1_ConfigMap|test-vars|test", "\"script\":\"echo ${HOME}\""),
+				),
+			},
+		},
+	})
+}
+
+func testAccDataSourceKustomizationConfig_variables() string {
+	return `
+data "kustomization" "test" {
+	path = "app"
+
+	variables = {
+		NAMESPACE = "test-vars"
+		CLUSTER   = "prod"
+	}
+	variables_strict = true
+
+	files = {
+		"app/kustomization.yaml" = <<-EOT
+			namespace: $${NAMESPACE}
+			resources:
+			- resources.yaml
+		EOT
+
+		"app/resources.yaml" = <<-EOT
+			apiVersion: v1
+			kind: Namespace
+			metadata:
+			  name: $${NAMESPACE}
+			---
+			apiVersion: v1
+			kind: ConfigMap
+			metadata:
+			  name: test
+			data:
+			  host: $${CLUSTER}.example.com
+			  script: echo $$${HOME}
+		EOT
+	}
+}
+`
+}
+
+func TestAccDataSourceKustomization_overrides(t *testing.T) {
+
+	resource.Test(t, resource.TestCase{
+		//PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceKustomizationConfig_overrides(`
+	replicas {
+		name  = "test"
+		count = 3
+	}
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.kustomization.test", "ids.#", "4"),
+					testAccCheckManifestContains("data.kustomization.test", "apps_v1_Deployment|test-basic|test", "\"image\":\"registry.example.com/nginx:1.19\""),
+					testAccCheckManifestContains("data.kustomization.test", "apps_v1_Deployment|test-basic|test", "\"replicas\":3"),
+				),
+			},
+			{
+				Config: testAccDataSourceKustomizationConfig_overrides(`
+	replicas {
+		name  = "missing"
+		count = 3
+	}
+`),
+				ExpectError: regexp.MustCompile("resource with name missing does not match"),
+			},
+		},
+	})
+}
+
+func testAccDataSourceKustomizationConfig_overrides(replicas string) string {
+	return fmt.Sprintf(`
+data "kustomization" "test" {
+	path = "../test_kustomizations/basic/initial"
+
+	images {
+		name     = "nginx"
+		new_name = "registry.example.com/nginx"
+		new_tag  = "1.19"
+	}
+%s
+}
+`, replicas)
+}
+
+func TestAccDataSourceKustomization_overlayOverrides(t *testing.T) {
+
+	resource.Test(t, resource.TestCase{
+		//PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceKustomizationConfig_overlayOverrides(),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.kustomization.test", "ids.#", "4"),
+					testAccCheckManifestContains("data.kustomization.test", "apps_v1_Deployment|tenant-a|tenant-a-test-v1", "\"tenant\":\"a\""),
+					testAccCheckManifestContains("data.kustomization.test", "apps_v1_Deployment|tenant-a|tenant-a-test-v1", "\"owner\":\"team-a\""),
+					testAccCheckManifestContains("data.kustomization.test", "~G_v1_Service|tenant-a|tenant-a-test-v1", "\"tenant\":\"a\""),
+					resource.TestCheckResourceAttr("data.kustomization.files", "ids.#", "1"),
+					testAccCheckManifestContains("data.kustomization.files", "~G_v1_ConfigMap|tenant-b|test", "\"tenant\":\"b\""),
+				),
+			},
+		},
+	})
+}
+
+func testAccDataSourceKustomizationConfig_overlayOverrides() string {
+	return `
+data "kustomization" "test" {
+	path = "../test_kustomizations/basic/initial"
+
+	namespace   = "tenant-a"
+	name_prefix = "tenant-a-"
+	name_suffix = "-v1"
+
+	common_labels = {
+		tenant = "a"
+	}
+
+	common_annotations = {
+		owner = "team-a"
+	}
+}
+
+data "kustomization" "files" {
+	path = "app"
+
+	namespace = "tenant-b"
+
+	common_labels = {
+		tenant = "b"
+	}
+
+	files = {
+		"app/kustomization.yaml" = <<-EOT
+			configMapGenerator:
+			- name: test
+			  literals:
+			  - key=value
+			generatorOptions:
+			  disableNameSuffixHash: true
+		EOT
+	}
+}
+`
+}
@@ -0,0 +1,824 @@
+package kustomize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/dynamic"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// fakeAPIServerEnvVar selects running the acceptance tests
+// against an in-process fake API server instead of a cluster
+const fakeAPIServerEnvVar = "TF_ACC_FAKE_API_SERVER"
+
+// fakeAPIServerResources are the built-in API resources
+// of the fake API server, in discovery order
+var fakeAPIServerResources = []*k8smetav1.APIResourceList{
+	{
+		GroupVersion: "v1",
+		APIResources: []k8smetav1.APIResource{
+			{Name: "namespaces", Kind: "Namespace", Namespaced: false, ShortNames: []string{"ns"}},
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, ShortNames: []string{"cm"}},
+			{Name: "secrets", Kind: "Secret", Namespaced: true},
+			{Name: "services", Kind: "Service", Namespaced: true},
+			{Name: "serviceaccounts", Kind: "ServiceAccount", Namespaced: true},
+		},
+	},
+	{
+		GroupVersion: "apps/v1",
+		APIResources: []k8smetav1.APIResource{
+			{Name: "deployments", Kind: "Deployment", Namespaced: true, ShortNames: []string{"deploy"}},
+			{Name: "statefulsets", Kind: "StatefulSet", Namespaced: true},
+			{Name: "daemonsets", Kind: "DaemonSet", Namespaced: true},
+		},
+	},
+	{
+		GroupVersion: "batch/v1",
+		APIResources: []k8smetav1.APIResource{
+			{Name: "jobs", Kind: "Job", Namespaced: true},
+		},
+	},
+	{
+		GroupVersion: "networking.k8s.io/v1beta1",
+		APIResources: []k8smetav1.APIResource{
+			{Name: "ingresses", Kind: "Ingress", Namespaced: true},
+		},
+	},
+	{
+		GroupVersion: "rbac.authorization.k8s.io/v1",
+		APIResources: []k8smetav1.APIResource{
+			{Name: "roles", Kind: "Role", Namespaced: true},
+			{Name: "rolebindings", Kind: "RoleBinding", Namespaced: true},
+			{Name: "clusterroles", Kind: "ClusterRole", Namespaced: false},
+			{Name: "clusterrolebindings", Kind: "ClusterRoleBinding", Namespaced: false},
+		},
+	},
+	{
+		GroupVersion: "apiextensions.k8s.io/v1",
+		APIResources: []k8smetav1.APIResource{
+			{Name: "customresourcedefinitions", Kind: "CustomResourceDefinition", Namespaced: false},
+		},
+	},
+	{
+		GroupVersion: "apiextensions.k8s.io/v1beta1",
+		APIResources: []k8smetav1.APIResource{
+			{Name: "customresourcedefinitions", Kind: "CustomResourceDefinition", Namespaced: false},
+		},
+	},
+}
+
+// fakeImmutableFields are the fields the fake API
+// server rejects changes to, per GroupKind
+var fakeImmutableFields = map[k8sschema.GroupKind][][]string{
+	{Group: "", Kind: "Service"}:                                     {{"spec", "clusterIP"}},
+	{Group: "apps", Kind: "Deployment"}:                              {{"spec", "selector"}},
+	{Group: "apps", Kind: "StatefulSet"}:                             {{"spec", "selector"}},
+	{Group: "apps", Kind: "DaemonSet"}:                               {{"spec", "selector"}},
+	{Group: "batch", Kind: "Job"}:                                    {{"spec", "selector"}, {"spec", "template"}},
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}:        {{"roleRef"}},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}: {{"roleRef"}},
+}
+
+// fakeAPIServerVerbs are the verbs discovery
+// lists for every resource of the fake API server
+var fakeAPIServerVerbs = k8smetav1.Verbs{"create", "delete", "get", "list", "patch", "update"}
+
+var crdGroupResource = k8sschema.GroupResource{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}
+
+var namespaceGroupResource = k8sschema.GroupResource{Group: "", Resource: "namespaces"}
+
+// fakeObjectKey identifies an object independent of the
+// version it was requested in
+type fakeObjectKey struct {
+	k8sschema.GroupResource
+	namespace string
+	name      string
+}
+
+// fakeAPIServer implements enough of the Kubernetes API to run
+// the resource acceptance tests without a cluster: discovery,
+// including custom resources, create, get, list, update,
+// strategic merge, merge and json patches, dry runs, immutable
+// fields and deletion honoring finalizers. Namespaces and CRDs
+// delete their namespaced objects or custom resources.
+type fakeAPIServer struct {
+	mu      sync.Mutex
+	objects map[fakeObjectKey]map[string]interface{}
+	version int
+}
+
+func newFakeAPIServer() *fakeAPIServer {
+	return &fakeAPIServer{
+		objects: make(map[fakeObjectKey]map[string]interface{}),
+	}
+}
+
+// startFakeAPIServer starts a fakeAPIServer and writes a
+// kubeconfig for it to dir, it returns the kubeconfig's path
+func startFakeAPIServer(dir string) (*httptest.Server, string, error) {
+	ts := httptest.NewServer(newFakeAPIServer())
+
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: fake
+  cluster:
+    server: %s
+contexts:
+- name: fake
+  context:
+    cluster: fake
+    user: fake
+current-context: fake
+users:
+- name: fake
+  user: {}
+`, ts.URL)
+
+	path := filepath.Join(dir, "kubeconfig")
+	err := ioutil.WriteFile(path, []byte(kubeconfig), 0600)
+	if err != nil {
+		ts.Close()
+		return nil, "", err
+	}
+
+	return ts, path, nil
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	var gv k8sschema.GroupVersion
+	var segments []string
+	switch {
+	case r.URL.Path == "/api":
+		writeFakeResponse(w, http.StatusOK, &k8smetav1.APIVersions{
+			TypeMeta: k8smetav1.TypeMeta{Kind: "APIVersions"},
+			Versions: []string{"v1"},
+		})
+		return
+	case r.URL.Path == "/apis":
+		writeFakeResponse(w, http.StatusOK, s.apiGroupList())
+		return
+	case parts[0] == "api" && len(parts) >= 2:
+		gv = k8sschema.GroupVersion{Version: parts[1]}
+		segments = parts[2:]
+	case parts[0] == "apis" && len(parts) >= 3:
+		gv = k8sschema.GroupVersion{Group: parts[1], Version: parts[2]}
+		segments = parts[3:]
+	default:
+		writeFakeError(w, k8serrors.NewNotFound(k8sschema.GroupResource{}, r.URL.Path))
+		return
+	}
+
+	resources := s.apiResourceList(gv)
+	if resources == nil {
+		writeFakeError(w, k8serrors.NewNotFound(k8sschema.GroupResource{}, r.URL.Path))
+		return
+	}
+
+	if len(segments) == 0 {
+		writeFakeResponse(w, http.StatusOK, resources)
+		return
+	}
+
+	namespace := ""
+	if segments[0] == "namespaces" && len(segments) >= 3 {
+		namespace = segments[1]
+		segments = segments[2:]
+	}
+	if len(segments) > 2 {
+		writeFakeError(w, k8serrors.NewMethodNotSupported(k8sschema.GroupResource{Group: gv.Group, Resource: segments[0]}, r.Method))
+		return
+	}
+
+	var ar *k8smetav1.APIResource
+	for i := range resources.APIResources {
+		if resources.APIResources[i].Name == segments[0] {
+			ar = &resources.APIResources[i]
+		}
+	}
+	if ar == nil || (!ar.Namespaced && namespace != "") {
+		writeFakeError(w, k8serrors.NewNotFound(k8sschema.GroupResource{}, r.URL.Path))
+		return
+	}
+
+	gvk := gv.WithKind(ar.Kind)
+	key := fakeObjectKey{
+		GroupResource: k8sschema.GroupResource{Group: gv.Group, Resource: ar.Name},
+		namespace:     namespace,
+	}
+	dryRun := r.URL.Query().Get("dryRun") == k8smetav1.DryRunAll
+
+	if len(segments) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			writeFakeResponse(w, http.StatusOK, s.list(gvk, key))
+		case http.MethodPost:
+			obj, err := readFakeObject(r)
+			if err == nil {
+				obj, err = s.create(gvk, ar, key, obj, dryRun)
+			}
+			writeFakeResult(w, http.StatusCreated, obj, err)
+		default:
+			writeFakeError(w, k8serrors.NewMethodNotSupported(key.GroupResource, r.Method))
+		}
+		return
+	}
+
+	key.name = segments[1]
+	current, found := s.objects[key]
+	if !found {
+		writeFakeError(w, k8serrors.NewNotFound(key.GroupResource, key.name))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeFakeResponse(w, http.StatusOK, current)
+	case http.MethodPut:
+		obj, err := readFakeObject(r)
+		if err == nil {
+			obj, err = s.update(gvk, key, current, obj, dryRun)
+		}
+		writeFakeResult(w, http.StatusOK, obj, err)
+	case http.MethodPatch:
+		obj, err := s.patch(gvk, key, current, r)
+		if err == nil {
+			obj, err = s.update(gvk, key, current, obj, dryRun)
+		}
+		writeFakeResult(w, http.StatusOK, obj, err)
+	case http.MethodDelete:
+		obj := s.delete(key, current, dryRun)
+		if obj == nil {
+			writeFakeResponse(w, http.StatusOK, &k8smetav1.Status{
+				TypeMeta: k8smetav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+				Status:   k8smetav1.StatusSuccess,
+			})
+			return
+		}
+		writeFakeResponse(w, http.StatusOK, obj)
+	default:
+		writeFakeError(w, k8serrors.NewMethodNotSupported(key.GroupResource, r.Method))
+	}
+}
+
+// crdResources returns the API resources defined by
+// the CRDs stored in the fake API server, per GroupVersion
+func (s *fakeAPIServer) crdResources() map[k8sschema.GroupVersion][]k8smetav1.APIResource {
+	resources := make(map[k8sschema.GroupVersion][]k8smetav1.APIResource)
+
+	for key, crd := range s.objects {
+		if key.GroupResource != crdGroupResource {
+			continue
+		}
+
+		group, _, _ := k8sunstructured.NestedString(crd, "spec", "group")
+		kind, _, _ := k8sunstructured.NestedString(crd, "spec", "names", "kind")
+		plural, _, _ := k8sunstructured.NestedString(crd, "spec", "names", "plural")
+		scope, _, _ := k8sunstructured.NestedString(crd, "spec", "scope")
+
+		var versions []string
+		if v, ok, _ := k8sunstructured.NestedString(crd, "spec", "version"); ok {
+			versions = append(versions, v)
+		}
+		vs, _, _ := k8sunstructured.NestedSlice(crd, "spec", "versions")
+		for _, v := range vs {
+			name, _, _ := k8sunstructured.NestedString(v.(map[string]interface{}), "name")
+			served, ok, _ := k8sunstructured.NestedBool(v.(map[string]interface{}), "served")
+			if name != "" && (served || !ok) {
+				versions = append(versions, name)
+			}
+		}
+
+		for _, v := range versions {
+			gv := k8sschema.GroupVersion{Group: group, Version: v}
+			resources[gv] = append(resources[gv], k8smetav1.APIResource{
+				Name:       plural,
+				Kind:       kind,
+				Namespaced: scope == "Namespaced",
+				Verbs:      fakeAPIServerVerbs,
+			})
+		}
+	}
+
+	return resources
+}
+
+// isCustomResource returns true if gr is defined by a CRD
+func (s *fakeAPIServer) isCustomResource(gr k8sschema.GroupResource) bool {
+	for gv, resources := range s.crdResources() {
+		for _, r := range resources {
+			if gv.Group == gr.Group && r.Name == gr.Resource {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (s *fakeAPIServer) apiResourceList(gv k8sschema.GroupVersion) *k8smetav1.APIResourceList {
+	for _, l := range fakeAPIServerResources {
+		if l.GroupVersion == gv.String() {
+			list := l.DeepCopy()
+			list.TypeMeta = k8smetav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"}
+			for i := range list.APIResources {
+				list.APIResources[i].Verbs = fakeAPIServerVerbs
+			}
+			return list
+		}
+	}
+
+	resources, ok := s.crdResources()[gv]
+	if !ok {
+		return nil
+	}
+
+	return &k8smetav1.APIResourceList{
+		TypeMeta:     k8smetav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: gv.String(),
+		APIResources: resources,
+	}
+}
+
+func (s *fakeAPIServer) apiGroupList() *k8smetav1.APIGroupList {
+	var gvs []k8sschema.GroupVersion
+	for _, l := range fakeAPIServerResources {
+		gv, _ := k8sschema.ParseGroupVersion(l.GroupVersion)
+		gvs = append(gvs, gv)
+	}
+
+	var crdGVs []k8sschema.GroupVersion
+	for gv := range s.crdResources() {
+		crdGVs = append(crdGVs, gv)
+	}
+	sort.Slice(crdGVs, func(i, j int) bool {
+		return crdGVs[i].String() < crdGVs[j].String()
+	})
+	gvs = append(gvs, crdGVs...)
+
+	list := &k8smetav1.APIGroupList{
+		TypeMeta: k8smetav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"},
+	}
+	groups := make(map[string]int)
+	for _, gv := range gvs {
+		if gv.Group == "" {
+			continue
+		}
+
+		version := k8smetav1.GroupVersionForDiscovery{GroupVersion: gv.String(), Version: gv.Version}
+		i, ok := groups[gv.Group]
+		if !ok {
+			groups[gv.Group] = len(list.Groups)
+			list.Groups = append(list.Groups, k8smetav1.APIGroup{
+				Name:             gv.Group,
+				PreferredVersion: version,
+			})
+			i = len(list.Groups) - 1
+		}
+		list.Groups[i].Versions = append(list.Groups[i].Versions, version)
+	}
+
+	return list
+}
+
+func (s *fakeAPIServer) list(gvk k8sschema.GroupVersionKind, key fakeObjectKey) map[string]interface{} {
+	var names []fakeObjectKey
+	for k := range s.objects {
+		if k.GroupResource == key.GroupResource && (key.namespace == "" || k.namespace == key.namespace) {
+			names = append(names, k)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i].namespace+"/"+names[i].name < names[j].namespace+"/"+names[j].name
+	})
+
+	items := make([]interface{}, 0, len(names))
+	for _, k := range names {
+		items = append(items, s.objects[k])
+	}
+
+	return map[string]interface{}{
+		"apiVersion": gvk.GroupVersion().String(),
+		"kind":       gvk.Kind + "List",
+		"metadata": map[string]interface{}{
+			"resourceVersion": fmt.Sprintf("%d", s.version),
+		},
+		"items": items,
+	}
+}
+
+func (s *fakeAPIServer) create(gvk k8sschema.GroupVersionKind, ar *k8smetav1.APIResource, key fakeObjectKey, obj map[string]interface{}, dryRun bool) (map[string]interface{}, error) {
+	u := &k8sunstructured.Unstructured{Object: obj}
+
+	if u.GroupVersionKind() != gvk {
+		return nil, k8serrors.NewBadRequest(fmt.Sprintf("expected %s, got %s", gvk, u.GroupVersionKind()))
+	}
+	if ar.Namespaced {
+		if key.namespace == "" {
+			return nil, k8serrors.NewBadRequest("the namespace of the object is missing")
+		}
+		if u.GetNamespace() != "" && u.GetNamespace() != key.namespace {
+			return nil, k8serrors.NewBadRequest("the namespace of the provided object does not match the namespace sent on the request")
+		}
+		u.SetNamespace(key.namespace)
+
+		ns, ok := s.objects[fakeObjectKey{GroupResource: namespaceGroupResource, name: key.namespace}]
+		if !ok {
+			return nil, k8serrors.NewNotFound(namespaceGroupResource, key.namespace)
+		}
+		if _, terminating, _ := k8sunstructured.NestedString(ns, "metadata", "deletionTimestamp"); terminating {
+			return nil, k8serrors.NewForbidden(key.GroupResource, u.GetName(), fmt.Errorf("unable to create new content in namespace %s because it is being terminated", key.namespace))
+		}
+	}
+	if u.GetName() == "" {
+		return nil, k8serrors.NewInvalid(gvk.GroupKind(), "", field.ErrorList{field.Required(field.NewPath("metadata", "name"), "name is required")})
+	}
+
+	key.name = u.GetName()
+	if _, exists := s.objects[key]; exists {
+		return nil, k8serrors.NewAlreadyExists(key.GroupResource, key.name)
+	}
+
+	s.version++
+	u.SetUID(k8stypes.UID(fmt.Sprintf("00000000-0000-0000-0000-%012d", s.version)))
+	u.SetResourceVersion(fmt.Sprintf("%d", s.version))
+	u.SetCreationTimestamp(k8smetav1.NewTime(time.Now()))
+	u.SetGeneration(1)
+	if key.GroupResource == namespaceGroupResource {
+		k8sunstructured.SetNestedField(u.Object, "Active", "status", "phase")
+	}
+
+	if !dryRun {
+		s.objects[key] = u.Object
+	}
+
+	return u.Object, nil
+}
+
+// patch returns current with the request's patch applied
+func (s *fakeAPIServer) patch(gvk k8sschema.GroupVersionKind, key fakeObjectKey, current map[string]interface{}, r *http.Request) (map[string]interface{}, error) {
+	patch, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, k8serrors.NewBadRequest(err.Error())
+	}
+
+	original, err := json.Marshal(current)
+	if err != nil {
+		return nil, k8serrors.NewInternalError(err)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var patched []byte
+	switch k8stypes.PatchType(contentType) {
+	case k8stypes.JSONPatchType:
+		p, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, k8serrors.NewBadRequest(err.Error())
+		}
+		patched, err = p.Apply(original)
+		if err != nil {
+			return nil, k8serrors.NewInvalid(gvk.GroupKind(), key.name, field.ErrorList{field.Invalid(nil, string(patch), err.Error())})
+		}
+	case k8stypes.MergePatchType:
+		patched, err = jsonpatch.MergePatch(original, patch)
+		if err != nil {
+			return nil, k8serrors.NewBadRequest(err.Error())
+		}
+	case k8stypes.StrategicMergePatchType:
+		// like the API server, only built-in kinds
+		// support strategic merge patches
+		if s.isCustomResource(key.GroupResource) {
+			return nil, &k8serrors.StatusError{ErrStatus: k8smetav1.Status{
+				Status:  k8smetav1.StatusFailure,
+				Code:    http.StatusUnsupportedMediaType,
+				Reason:  k8smetav1.StatusReasonUnsupportedMediaType,
+				Message: "the body of the request was in an unknown format - accepted media types include: application/json-patch+json, application/merge-patch+json",
+			}}
+		}
+
+		typed, err := clientgoscheme.Scheme.New(gvk)
+		if err == nil {
+			patched, err = strategicpatch.StrategicMergePatch(original, patch, typed)
+		} else {
+			patched, err = strategicpatch.StrategicMergePatchUsingLookupPatchMeta(original, patch, schemalessPatchMeta{})
+		}
+		if err != nil {
+			return nil, k8serrors.NewBadRequest(err.Error())
+		}
+	default:
+		return nil, &k8serrors.StatusError{ErrStatus: k8smetav1.Status{
+			Status:  k8smetav1.StatusFailure,
+			Code:    http.StatusUnsupportedMediaType,
+			Reason:  k8smetav1.StatusReasonUnsupportedMediaType,
+			Message: fmt.Sprintf("unsupported patch type '%s'", contentType),
+		}}
+	}
+
+	obj := make(map[string]interface{})
+	err = json.Unmarshal(patched, &obj)
+	if err != nil {
+		return nil, k8serrors.NewBadRequest(err.Error())
+	}
+
+	return obj, nil
+}
+
+// update replaces current with obj, after validating immutable
+// fields and carrying over the fields managed by the server
+func (s *fakeAPIServer) update(gvk k8sschema.GroupVersionKind, key fakeObjectKey, current map[string]interface{}, obj map[string]interface{}, dryRun bool) (map[string]interface{}, error) {
+	c := &k8sunstructured.Unstructured{Object: current}
+	u := &k8sunstructured.Unstructured{Object: obj}
+
+	if u.GroupVersionKind().GroupKind() != gvk.GroupKind() {
+		return nil, k8serrors.NewBadRequest(fmt.Sprintf("expected %s, got %s", gvk, u.GroupVersionKind()))
+	}
+	if u.GetName() != c.GetName() {
+		return nil, k8serrors.NewBadRequest("the name of the object does not match the name on the URL")
+	}
+	if u.GetResourceVersion() != "" && u.GetResourceVersion() != c.GetResourceVersion() {
+		return nil, k8serrors.NewConflict(key.GroupResource, key.name, fmt.Errorf("the object has been modified; please apply your changes to the latest version and try again"))
+	}
+	u.SetNamespace(c.GetNamespace())
+
+	var errs field.ErrorList
+	for _, path := range fakeImmutableFields[gvk.GroupKind()] {
+		cv, _, _ := k8sunstructured.NestedFieldNoCopy(current, path...)
+		uv, _, _ := k8sunstructured.NestedFieldNoCopy(obj, path...)
+		if !reflect.DeepEqual(cv, uv) {
+			errs = append(errs, field.Invalid(field.NewPath(path[0], path[1:]...), uv, "field is immutable"))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, k8serrors.NewInvalid(gvk.GroupKind(), key.name, errs)
+	}
+
+	s.version++
+	u.SetUID(c.GetUID())
+	u.SetCreationTimestamp(c.GetCreationTimestamp())
+	u.SetDeletionTimestamp(c.GetDeletionTimestamp())
+	u.SetResourceVersion(fmt.Sprintf("%d", s.version))
+	u.SetGeneration(c.GetGeneration())
+	if !reflect.DeepEqual(current["spec"], obj["spec"]) {
+		u.SetGeneration(c.GetGeneration() + 1)
+	}
+
+	if dryRun {
+		return u.Object, nil
+	}
+
+	s.objects[key] = u.Object
+	if u.GetDeletionTimestamp() != nil && len(u.GetFinalizers()) == 0 {
+		s.remove(key)
+	}
+
+	return u.Object, nil
+}
+
+// delete removes the object or, if it has finalizers, marks it
+// as being deleted, it returns the object if it still exists
+func (s *fakeAPIServer) delete(key fakeObjectKey, current map[string]interface{}, dryRun bool) map[string]interface{} {
+	if dryRun {
+		return nil
+	}
+
+	u := &k8sunstructured.Unstructured{Object: current}
+	if u.GetDeletionTimestamp() == nil {
+		now := k8smetav1.NewTime(time.Now())
+		u.SetDeletionTimestamp(&now)
+		s.version++
+		u.SetResourceVersion(fmt.Sprintf("%d", s.version))
+	}
+
+	empty := true
+	if key.GroupResource == namespaceGroupResource {
+		// the namespace controller deletes the namespace's
+		// content, before the namespace itself is removed
+		k8sunstructured.SetNestedField(u.Object, "Terminating", "status", "phase")
+		for k, obj := range s.objects {
+			if k.namespace == key.name {
+				s.delete(k, obj, false)
+			}
+		}
+		for k := range s.objects {
+			if k.namespace == key.name {
+				empty = false
+			}
+		}
+	}
+
+	if empty && len(u.GetFinalizers()) == 0 {
+		s.remove(key)
+	}
+
+	if _, exists := s.objects[key]; exists {
+		return u.Object
+	}
+
+	return nil
+}
+
+// remove removes the object and anything that only
+// existed because of it, like custom resources of a CRD
+func (s *fakeAPIServer) remove(key fakeObjectKey) {
+	obj := s.objects[key]
+	delete(s.objects, key)
+
+	if key.GroupResource == crdGroupResource {
+		group, _, _ := k8sunstructured.NestedString(obj, "spec", "group")
+		plural, _, _ := k8sunstructured.NestedString(obj, "spec", "names", "plural")
+		for k := range s.objects {
+			if k.Group == group && k.Resource == plural {
+				delete(s.objects, k)
+			}
+		}
+	}
+
+	// remove terminating namespaces once they are empty
+	if key.namespace != "" {
+		nsKey := fakeObjectKey{GroupResource: namespaceGroupResource, name: key.namespace}
+		ns, ok := s.objects[nsKey]
+		if !ok {
+			return
+		}
+		if _, terminating, _ := k8sunstructured.NestedString(ns, "metadata", "deletionTimestamp"); !terminating {
+			return
+		}
+		for k := range s.objects {
+			if k.namespace == key.namespace {
+				return
+			}
+		}
+		if len((&k8sunstructured.Unstructured{Object: ns}).GetFinalizers()) == 0 {
+			delete(s.objects, nsKey)
+		}
+	}
+}
+
+func readFakeObject(r *http.Request) (map[string]interface{}, error) {
+	obj := make(map[string]interface{})
+	err := json.NewDecoder(r.Body).Decode(&obj)
+	if err != nil {
+		return nil, k8serrors.NewBadRequest(err.Error())
+	}
+
+	return obj, nil
+}
+
+func writeFakeResult(w http.ResponseWriter, code int, obj interface{}, err error) {
+	if err != nil {
+		writeFakeError(w, err)
+		return
+	}
+
+	writeFakeResponse(w, code, obj)
+}
+
+func writeFakeError(w http.ResponseWriter, err error) {
+	status := err.(k8serrors.APIStatus).Status()
+	status.TypeMeta = k8smetav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+
+	writeFakeResponse(w, int(status.Code), &status)
+}
+
+func writeFakeResponse(w http.ResponseWriter, code int, obj interface{}) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// setupFakeAPIServer starts the fake API server, if selected by
+// fakeAPIServerEnvVar, and points the provider's kubeconfig to it
+func setupFakeAPIServer() (teardown func(), err error) {
+	if os.Getenv(fakeAPIServerEnvVar) == "" {
+		return func() {}, nil
+	}
+
+	dir, err := ioutil.TempDir("", "terraform-provider-kustomize")
+	if err != nil {
+		return nil, err
+	}
+
+	ts, path, err := startFakeAPIServer(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	// KUBE_CONFIG takes precedence over KUBECONFIG
+	os.Setenv("KUBE_CONFIG", path)
+
+	return func() {
+		ts.Close()
+		os.RemoveAll(dir)
+	}, nil
+}
+
+func TestFakeAPIServerFinalizers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terraform-provider-kustomize")
+	if err != nil {
+		t.Fatalf("TestFakeAPIServerFinalizers: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ts, _, err := startFakeAPIServer(dir)
+	if err != nil {
+		t.Fatalf("TestFakeAPIServerFinalizers: %s", err)
+	}
+	defer ts.Close()
+
+	client, err := dynamic.NewForConfig(&rest.Config{Host: ts.URL})
+	if err != nil {
+		t.Fatalf("TestFakeAPIServerFinalizers: %s", err)
+	}
+
+	nsGVR := k8sschema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	cmGVR := k8sschema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	ns := &k8sunstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName("test-fake")
+	_, err = client.Resource(nsGVR).Create(context.TODO(), ns, k8smetav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("TestFakeAPIServerFinalizers: %s", err)
+	}
+
+	cm := &k8sunstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetName("test")
+	cm.SetFinalizers([]string{"test.example.com/finalizer"})
+	_, err = client.Resource(cmGVR).Namespace("test-fake").Create(context.TODO(), cm, k8smetav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("TestFakeAPIServerFinalizers: %s", err)
+	}
+
+	err = client.Resource(nsGVR).Delete(context.TODO(), "test-fake", k8smetav1.DeleteOptions{})
+	if err != nil {
+		t.Fatalf("TestFakeAPIServerFinalizers: %s", err)
+	}
+
+	resp, err := client.Resource(cmGVR).Namespace("test-fake").Get(context.TODO(), "test", k8smetav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("TestFakeAPIServerFinalizers: expected ConfigMap with finalizer to still exist: %s", err)
+	}
+	if resp.GetDeletionTimestamp() == nil {
+		t.Errorf("TestFakeAPIServerFinalizers: expected ConfigMap to be marked as deleted")
+	}
+
+	_, err = client.Resource(cmGVR).Namespace("test-fake").Create(context.TODO(), cm, k8smetav1.CreateOptions{})
+	if !k8serrors.IsForbidden(err) {
+		t.Errorf("TestFakeAPIServerFinalizers: expected creating in terminating namespace to be forbidden, got: %v.", err)
+	}
+
+	_, err = client.Resource(cmGVR).Namespace("test-fake").Patch(context.TODO(), "test", k8stypes.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`), k8smetav1.PatchOptions{})
+	if err != nil {
+		t.Fatalf("TestFakeAPIServerFinalizers: %s", err)
+	}
+
+	_, err = client.Resource(cmGVR).Namespace("test-fake").Get(context.TODO(), "test", k8smetav1.GetOptions{})
+	if !k8serrors.IsNotFound(err) {
+		t.Errorf("TestFakeAPIServerFinalizers: expected ConfigMap to be removed, got: %v.", err)
+	}
+
+	_, err = client.Resource(nsGVR).Get(context.TODO(), "test-fake", k8smetav1.GetOptions{})
+	if !k8serrors.IsNotFound(err) {
+		t.Errorf("TestFakeAPIServerFinalizers: expected namespace to be removed, got: %v.", err)
+	}
+}
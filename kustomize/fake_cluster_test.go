@@ -0,0 +1,206 @@
+package kustomize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	k8sserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeAPIResources are the API resources the fake cluster's
+// discovery reports
+var fakeAPIResources = []*k8smetav1.APIResourceList{
+	{
+		GroupVersion: "v1",
+		APIResources: []k8smetav1.APIResource{
+			{Name: "namespaces", Kind: "Namespace", Namespaced: false},
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+			{Name: "secrets", Kind: "Secret", Namespaced: true},
+			{Name: "services", Kind: "Service", Namespaced: true},
+		},
+	},
+	{
+		GroupVersion: "apps/v1",
+		APIResources: []k8smetav1.APIResource{
+			{Name: "deployments", Kind: "Deployment", Namespaced: true},
+		},
+	},
+	{
+		GroupVersion: "apiextensions.k8s.io/v1",
+		APIResources: []k8smetav1.APIResource{
+			{Name: "customresourcedefinitions", Kind: "CustomResourceDefinition", Namespaced: false},
+		},
+	},
+}
+
+// fakeCluster is an offline stand in for a Kubernetes API
+// server, based on client-go's fake dynamic client and discovery.
+// Responses can be scripted per verb and resource with failOn.
+//
+// The fake client does not support dry runs, dry run
+// patches are applied like any other patch.
+type fakeCluster struct {
+	t         *testing.T
+	client    *dynamicfake.FakeDynamicClient
+	discovery *discoveryfake.FakeDiscovery
+	tracker   k8stesting.ObjectTracker
+	uids      int
+}
+
+func newFakeCluster(t *testing.T, objects ...k8sruntime.Object) *fakeCluster {
+	scheme := k8sruntime.NewScheme()
+	tracker := k8stesting.NewObjectTracker(scheme, k8sserializer.NewCodecFactory(scheme).UniversalDecoder())
+	for _, obj := range objects {
+		err := tracker.Add(obj)
+		if err != nil {
+			t.Fatalf("newFakeCluster: %s", err)
+		}
+	}
+
+	c := &fakeCluster{
+		t:      t,
+		client: dynamicfake.NewSimpleDynamicClient(scheme),
+		discovery: &discoveryfake.FakeDiscovery{
+			Fake: &k8stesting.Fake{Resources: fakeAPIResources},
+		},
+		tracker: tracker,
+	}
+
+	// use our own tracker, to have access to it in the patch reactor
+	c.client.PrependReactor("*", "*", k8stesting.ObjectReaction(tracker))
+
+	// the tracker can only strategic merge patch typed objects
+	c.client.PrependReactor("patch", "*", c.strategicMergePatchReaction)
+
+	// the object tracker does not set UIDs, but the
+	// provider uses them as the resource ID
+	c.client.PrependReactor("create", "*", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		obj := action.(k8stesting.CreateAction).GetObject().(*k8sunstructured.Unstructured)
+		if obj.GetUID() == "" {
+			c.uids++
+			obj.SetUID(k8stypes.UID(fmt.Sprintf("00000000-0000-0000-0000-%012d", c.uids)))
+		}
+
+		return false, nil, nil
+	})
+
+	return c
+}
+
+// strategicMergePatchReaction applies strategic merge patches without
+// patch metadata, the fake cluster has no OpenAPI schemas
+func (c *fakeCluster) strategicMergePatchReaction(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+	pa := action.(k8stesting.PatchAction)
+	if pa.GetPatchType() != k8stypes.StrategicMergePatchType {
+		return false, nil, nil
+	}
+
+	obj, err := c.tracker.Get(pa.GetResource(), pa.GetNamespace(), pa.GetName())
+	if err != nil {
+		return true, nil, err
+	}
+
+	original, err := k8sruntime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return true, nil, err
+	}
+
+	patch := make(map[string]interface{})
+	err = json.Unmarshal(pa.GetPatch(), &patch)
+	if err != nil {
+		return true, nil, k8serrors.NewBadRequest(err.Error())
+	}
+
+	patched, err := strategicpatch.StrategicMergeMapPatchUsingLookupPatchMeta(original, patch, schemalessPatchMeta{})
+	if err != nil {
+		return true, nil, k8serrors.NewBadRequest(err.Error())
+	}
+
+	u := &k8sunstructured.Unstructured{Object: patched}
+	err = c.tracker.Update(pa.GetResource(), u, pa.GetNamespace())
+	if err != nil {
+		return true, nil, err
+	}
+
+	return true, u, nil
+}
+
+// schemalessPatchMeta has no patch strategies or merge
+// keys, maps are merged and lists are replaced
+type schemalessPatchMeta struct{}
+
+func (s schemalessPatchMeta) LookupPatchMetadataForStruct(key string) (strategicpatch.LookupPatchMeta, strategicpatch.PatchMeta, error) {
+	return s, strategicpatch.PatchMeta{}, nil
+}
+
+func (s schemalessPatchMeta) LookupPatchMetadataForSlice(key string) (strategicpatch.LookupPatchMeta, strategicpatch.PatchMeta, error) {
+	return s, strategicpatch.PatchMeta{}, nil
+}
+
+func (s schemalessPatchMeta) Name() string {
+	return "schemaless"
+}
+
+// config returns a provider Config using the fake cluster
+func (c *fakeCluster) config() *Config {
+	return &Config{
+		Client:                 c.client,
+		Discovery:              c.discovery,
+		CachedGroupVersionKind: newCachedGroupVersionKind(c.discovery),
+	}
+}
+
+// failOn makes all requests with verb for resource return err
+func (c *fakeCluster) failOn(verb string, resource string, err error) {
+	c.client.PrependReactor(verb, resource, func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		return true, nil, err
+	})
+}
+
+// actions returns the verbs of the requests sent for resource so far
+func (c *fakeCluster) actions(resource string) (verbs []string) {
+	for _, a := range c.client.Actions() {
+		if a.GetResource().Resource == resource {
+			verbs = append(verbs, a.GetVerb())
+		}
+	}
+
+	return verbs
+}
+
+// get returns the object from the fake cluster and fails
+// the test if it does not exist
+func (c *fakeCluster) get(gvr k8sschema.GroupVersionResource, namespace string, name string) *k8sunstructured.Unstructured {
+	u, err := c.client.
+		Resource(gvr).
+		Namespace(namespace).
+		Get(context.TODO(), name, k8smetav1.GetOptions{})
+	if err != nil {
+		c.t.Fatalf("fakeCluster: getting '%s/%s' failed: %s", namespace, name, err)
+	}
+
+	return u
+}
+
+// fakeObject returns an Unstructured from a JSON manifest and
+// fails the test if it can not be parsed
+func fakeObject(t *testing.T, manifest string) *k8sunstructured.Unstructured {
+	u, err := parseJSON(manifest)
+	if err != nil {
+		t.Fatalf("fakeObject: %s", err)
+	}
+
+	return u
+}
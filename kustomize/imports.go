@@ -0,0 +1,166 @@
+package kustomize
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"sigs.k8s.io/kustomize/api/resid"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const importsUsage = `Usage: terraform-provider-kustomization imports -address ADDRESS [options] [path]
+
+Builds a kustomization like the render subcommand and prints a terraform
+import command, or with -format hcl an import block (Terraform 1.5+), for
+every resource. ADDRESS is the kustomization_resource using for_each over
+the data source's ids, e.g. kustomization_resource.example.
+
+With -check, only resources that exist in the cluster are imported,
+using the kubeconfig like the provider does.
+
+Options:
+`
+
+// Imports implements the imports subcommand, it builds a kustomization
+// and writes the commands or blocks to import its resources to w
+func Imports(args []string, w io.Writer) error {
+	fs := newSubcommandFlagSet("imports", importsUsage)
+	address := fs.String("address", "", "Address of the kustomization_resource with for_each over the ids. Required.")
+	format := fs.String("format", "script", "Output format, script for a shell script of terraform import commands, or hcl for import blocks.")
+	check := fs.Bool("check", false, "Only import resources that exist in the cluster.")
+	kubeconfigPath := fs.String("kubeconfig", envDefault("KUBE_CONFIG", envDefault("KUBECONFIG", kubeconfigDefault)), "Path to the kubeconfig file used with -check.")
+	kubeContext := fs.String("context", "", "Context of the kubeconfig used with -check. Defaults to the current context.")
+	bf := addBuildFlags(fs)
+
+	err := fs.Parse(args)
+	if err == flag.ErrHelp {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if *address == "" {
+		return fmt.Errorf("-address is required")
+	}
+	if *format != "script" && *format != "hcl" {
+		return fmt.Errorf("unsupported format '%s', expected script or hcl", *format)
+	}
+
+	m := &Config{}
+	if *check {
+		config, err := loadRESTConfig(*kubeconfigPath, *kubeContext)
+		if err != nil {
+			return err
+		}
+		client, dc, err := newKubernetesClients(config)
+		if err != nil {
+			return err
+		}
+		m.Client = client
+		m.Discovery = dc
+		m.CachedGroupVersionKind = newCachedGroupVersionKind(dc)
+	}
+
+	d, err := bf.build(fs, m)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	for _, id := range d.Get("ids").(*schema.Set).List() {
+		ids = append(ids, id.(string))
+	}
+	sort.Strings(ids)
+
+	if *format == "script" {
+		fmt.Fprint(w, "#!/bin/sh\nset -e\n\n")
+	}
+
+	for _, id := range ids {
+		if *check {
+			exists, err := resourceExistsByID(m, id)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				fmt.Fprintf(w, "# not found in the cluster: %s\n", id)
+				continue
+			}
+		}
+
+		to := fmt.Sprintf("%s[%s]", *address, quoteHCLString(id))
+		if *format == "script" {
+			fmt.Fprintf(w, "terraform import %s %s\n", quoteShellString(to), quoteShellString(id))
+			continue
+		}
+
+		_, err = fmt.Fprintf(w, "import {\n  to = %s\n  id = %s\n}\n\n", to, quoteHCLString(id))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resourceExistsByID returns true if the resource with the
+// kustomize resource ID exists in the cluster. Kinds the
+// cluster does not know can't exist either.
+func resourceExistsByID(m *Config, id string) (bool, error) {
+	rid := resid.FromString(id)
+
+	rm, err := m.CachedGroupVersionKind.getRESTMapper(false)
+	if err != nil {
+		return false, err
+	}
+
+	gk := k8sschema.GroupKind{Group: rid.Gvk.Group, Kind: rid.Gvk.Kind}
+	mapping, err := rm.RESTMapping(gk, rid.Gvk.Version)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("mapping GroupKind failed for '%s': %s", gk, err)
+	}
+	gvr := mapping.Resource
+
+	logger := resourceLogger{operation: "imports", resid: id}
+
+	start := time.Now()
+	_, err = m.Client.
+		Resource(gvr).
+		Namespace(rid.Namespace).
+		Get(context.TODO(), rid.Name, k8smetav1.GetOptions{})
+	logger.apiCall("get", gvr, rid.Namespace, rid.Name, start, err)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("reading '%s' failed: %s", id, err)
+	}
+
+	return true, nil
+}
+
+// quoteShellString quotes s for POSIX shells
+func quoteShellString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// quoteHCLString quotes s as a HCL string literal,
+// escaping template sequences
+func quoteHCLString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "${", "$${", "%{", "%%{")
+	return `"` + r.Replace(s) + `"`
+}
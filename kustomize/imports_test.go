@@ -0,0 +1,167 @@
+package kustomize
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+func TestImportsScript(t *testing.T) {
+	var out bytes.Buffer
+	err := Imports([]string{"-address", "kustomization_resource.test", "../test_kustomizations/basic/initial"}, &out)
+	if err != nil {
+		t.Fatalf("TestImportsScript: %s", err)
+	}
+
+	want := `terraform import 'kustomization_resource.test["apps_v1_Deployment|test-basic|test"]' 'apps_v1_Deployment|test-basic|test'` + "\n"
+	if !strings.HasPrefix(out.String(), "#!/bin/sh\n") || !strings.Contains(out.String(), want) {
+		t.Errorf("TestImportsScript: unexpected script, got: %s, want: %s.", out.String(), want)
+	}
+	if strings.Count(out.String(), "terraform import") != 4 {
+		t.Errorf("TestImportsScript: expected 4 import commands, got: %s.", out.String())
+	}
+}
+
+func TestImportsHCL(t *testing.T) {
+	var out bytes.Buffer
+	err := Imports([]string{"-address", "module.app.kustomization_resource.test", "-format", "hcl", "../test_kustomizations/basic/initial"}, &out)
+	if err != nil {
+		t.Fatalf("TestImportsHCL: %s", err)
+	}
+
+	want := `import {
+  to = module.app.kustomization_resource.test["~G_v1_Namespace|~X|test-basic"]
+  id = "~G_v1_Namespace|~X|test-basic"
+}
+`
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("TestImportsHCL: unexpected import blocks, got: %s, want: %s.", out.String(), want)
+	}
+}
+
+func TestImportsCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kustomize-imports")
+	if err != nil {
+		t.Fatalf("TestImportsCheck: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ts, kubeconfig, err := startFakeAPIServer(dir)
+	if err != nil {
+		t.Fatalf("TestImportsCheck: %s", err)
+	}
+	defer ts.Close()
+
+	client, err := dynamic.NewForConfig(&rest.Config{Host: ts.URL})
+	if err != nil {
+		t.Fatalf("TestImportsCheck: %s", err)
+	}
+	ns := fakeObject(t, `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"test-basic"}}`)
+	_, err = client.
+		Resource(k8sschema.GroupVersionResource{Version: "v1", Resource: "namespaces"}).
+		Create(context.TODO(), ns, k8smetav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("TestImportsCheck: %s", err)
+	}
+
+	var out bytes.Buffer
+	err = Imports([]string{"-address", "kustomization_resource.test", "-check", "-kubeconfig", kubeconfig, "../test_kustomizations/basic/initial"}, &out)
+	if err != nil {
+		t.Fatalf("TestImportsCheck: %s", err)
+	}
+
+	if strings.Count(out.String(), "terraform import") != 1 || !strings.Contains(out.String(), `'~G_v1_Namespace|~X|test-basic'`) {
+		t.Errorf("TestImportsCheck: expected only the existing namespace to be imported, got: %s.", out.String())
+	}
+	if !strings.Contains(out.String(), "# not found in the cluster: apps_v1_Deployment|test-basic|test\n") {
+		t.Errorf("TestImportsCheck: expected missing Deployment to be reported, got: %s.", out.String())
+	}
+}
+
+func TestQuoteHCLString(t *testing.T) {
+	got := quoteHCLString(`a"b\c${d}%{e}`)
+	want := `"a\"b\\c$${d}%%{e}"`
+	if got != want {
+		t.Errorf("TestQuoteHCLString: got: %s, want: %s.", got, want)
+	}
+}
+
+func TestImportsCheckErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kustomize-imports")
+	if err != nil {
+		t.Fatalf("TestImportsCheckErrors: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ts, kubeconfig, err := startFakeAPIServer(dir)
+	if err != nil {
+		t.Fatalf("TestImportsCheckErrors: %s", err)
+	}
+	// discovery fails, because the cluster is unreachable
+	ts.Close()
+
+	cases := []struct {
+		name       string
+		kubeconfig string
+		want       string
+	}{
+		{"missing kubeconfig", filepath.Join(dir, "nonexistent"), "reading kubeconfig"},
+		{"unreachable cluster", kubeconfig, "discovering API group resources failed"},
+	}
+
+	for _, tc := range cases {
+		var out bytes.Buffer
+		err = Imports([]string{"-address", "kustomization_resource.test", "-check", "-kubeconfig", tc.kubeconfig, "../test_kustomizations/basic/initial"}, &out)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("TestImportsCheckErrors: %s: expected error containing '%s', got: %v.", tc.name, tc.want, err)
+		}
+		if strings.Contains(out.String(), "not found in the cluster") {
+			t.Errorf("TestImportsCheckErrors: %s: expected no resources reported as not found, got: %s.", tc.name, out.String())
+		}
+	}
+}
+
+func TestResourceExistsByIDUnknownKind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kustomize-imports")
+	if err != nil {
+		t.Fatalf("TestResourceExistsByIDUnknownKind: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ts, kubeconfig, err := startFakeAPIServer(dir)
+	if err != nil {
+		t.Fatalf("TestResourceExistsByIDUnknownKind: %s", err)
+	}
+	defer ts.Close()
+
+	config, err := loadRESTConfig(kubeconfig, "")
+	if err != nil {
+		t.Fatalf("TestResourceExistsByIDUnknownKind: %s", err)
+	}
+	client, dc, err := newKubernetesClients(config)
+	if err != nil {
+		t.Fatalf("TestResourceExistsByIDUnknownKind: %s", err)
+	}
+	m := &Config{
+		Client:                 client,
+		Discovery:              dc,
+		CachedGroupVersionKind: newCachedGroupVersionKind(dc),
+	}
+
+	exists, err := resourceExistsByID(m, "example.com_v1_Unknown|test|test")
+	if err != nil {
+		t.Fatalf("TestResourceExistsByIDUnknownKind: %s", err)
+	}
+	if exists {
+		t.Errorf("TestResourceExistsByIDUnknownKind: expected unknown kind to not exist")
+	}
+}
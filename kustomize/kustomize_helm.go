@@ -0,0 +1,300 @@
+package kustomize
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const helmChartHomeDefault = "charts"
+
+const helmChartInflationGeneratorKind = "HelmChartInflationGenerator"
+
+// helmChart holds the arguments of a helmCharts entry or a
+// HelmChartInflationGenerator config, following kustomize's
+// field names
+type helmChart struct {
+	Name         string                      `yaml:"name"`
+	Version      string                      `yaml:"version"`
+	Repo         string                      `yaml:"repo"`
+	ReleaseName  string                      `yaml:"releaseName"`
+	Namespace    string                      `yaml:"namespace"`
+	ValuesFile   string                      `yaml:"valuesFile"`
+	ValuesInline map[interface{}]interface{} `yaml:"valuesInline"`
+	IncludeCRDs  bool                        `yaml:"includeCRDs"`
+	ChartHome    string                      `yaml:"chartHome"`
+}
+
+type helmGlobals struct {
+	ChartHome string `yaml:"chartHome"`
+}
+
+// helmPreprocessor inflates local helm charts using the helm binary
+// and adds the rendered manifests to the kustomization's resources.
+func helmPreprocessor(helmPath string) kustomizationPreprocessor {
+	return func(fs fsPreprocessor, dir string, kustomization map[interface{}]interface{}) error {
+		var globals helmGlobals
+		err := convertYaml(kustomization["helmGlobals"], &globals)
+		if err != nil {
+			return fmt.Errorf("helmGlobals: %s", err)
+		}
+		delete(kustomization, "helmGlobals")
+
+		var charts []helmChart
+		err = convertYaml(kustomization["helmCharts"], &charts)
+		if err != nil {
+			return fmt.Errorf("helmCharts: %s", err)
+		}
+		delete(kustomization, "helmCharts")
+
+		generatorCharts, err := extractHelmChartGenerators(fs, dir, kustomization)
+		if err != nil {
+			return err
+		}
+		charts = append(charts, generatorCharts...)
+
+		for ix, chart := range charts {
+			if chart.ChartHome == "" {
+				chart.ChartHome = globals.ChartHome
+			}
+
+			manifests, err := inflateHelmChart(fs, dir, helmPath, chart)
+			if err != nil {
+				return fmt.Errorf("helm chart %d '%s': %s", ix, chart.Name, err)
+			}
+
+			name := fmt.Sprintf("helm_chart_%d_%s.yaml", ix, chart.Name)
+			err = fs.overlay.WriteFile(filepath.Join(dir, name), manifests)
+			if err != nil {
+				return err
+			}
+
+			appendKustomizationResource(kustomization, name)
+		}
+
+		return nil
+	}
+}
+
+// extractHelmChartGenerators removes HelmChartInflationGenerator
+// configs from the kustomization's generators and returns them
+func extractHelmChartGenerators(fs fsPreprocessor, dir string, kustomization map[interface{}]interface{}) (charts []helmChart, err error) {
+	generators, ok := kustomization["generators"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	var remaining []interface{}
+	for _, g := range generators {
+		path, ok := g.(string)
+		if !ok {
+			remaining = append(remaining, g)
+			continue
+		}
+
+		data, err := fs.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			// leave reporting missing files to kustomize
+			remaining = append(remaining, g)
+			continue
+		}
+
+		var meta struct {
+			Kind string `yaml:"kind"`
+		}
+		err = yaml.Unmarshal(data, &meta)
+		if err != nil || meta.Kind != helmChartInflationGeneratorKind {
+			remaining = append(remaining, g)
+			continue
+		}
+
+		var chart helmChart
+		err = yaml.Unmarshal(data, &chart)
+		if err != nil {
+			return nil, fmt.Errorf("generator '%s': %s", path, err)
+		}
+		charts = append(charts, chart)
+	}
+
+	if len(remaining) == 0 {
+		delete(kustomization, "generators")
+	} else {
+		kustomization["generators"] = remaining
+	}
+
+	return charts, nil
+}
+
+func inflateHelmChart(fs fsPreprocessor, dir string, helmPath string, chart helmChart) ([]byte, error) {
+	if chart.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	chartPath, err := findLocalHelmChart(fs, dir, chart)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := helmChartValues(fs, dir, chart)
+	if err != nil {
+		return nil, err
+	}
+
+	releaseName := chart.ReleaseName
+	if releaseName == "" {
+		releaseName = chart.Name
+	}
+
+	args := []string{"template", releaseName, chartPath, "--values", "-"}
+	if chart.Namespace != "" {
+		args = append(args, "--namespace", chart.Namespace)
+	}
+	if chart.IncludeCRDs {
+		args = append(args, "--include-crds")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(helmPath, args...)
+	cmd.Stdin = bytes.NewReader(values)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("running '%s %s' failed: %s: %s", helmPath, strings.Join(args, " "), err, stderr.String())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// findLocalHelmChart returns the path to a chart directory or chart
+// archive inside the chart home. Charts are never pulled from
+// their repo. The helm binary reads the chart itself, so only
+// charts on disk can be used, not in-memory files. Like kustomize's
+// LoadRestrictionsRootOnly, the chart home has to be inside the
+// kustomization's root.
+func findLocalHelmChart(fs fsPreprocessor, dir string, chart helmChart) (string, error) {
+	chartHome := chart.ChartHome
+	if chartHome == "" {
+		chartHome = helmChartHomeDefault
+	}
+	chartHome, err := resolveInKustomizationRoot(dir, "chartHome", chartHome)
+	if err != nil {
+		return "", err
+	}
+
+	candidates := []string{filepath.Join(chartHome, chart.Name)}
+	if chart.Version != "" {
+		candidates = append(candidates, filepath.Join(chartHome, fmt.Sprintf("%s-%s.tgz", chart.Name, chart.Version)))
+	}
+	candidates = append(candidates, filepath.Join(chartHome, fmt.Sprintf("%s.tgz", chart.Name)))
+
+	for _, c := range candidates {
+		if !fs.Exists(c) {
+			continue
+		}
+		if _, err := os.Stat(c); err != nil {
+			return "", fmt.Errorf(
+				"chart '%s' only exists in memory, e.g. in files or inline in a template or overlay, helm can only render charts on disk",
+				c)
+		}
+		return c, nil
+	}
+
+	return "", fmt.Errorf(
+		"no local chart directory or archive found in '%s', only local charts are supported, tried: %s",
+		chartHome,
+		strings.Join(candidates, ", "))
+}
+
+// resolveInKustomizationRoot returns path joined to dir, if it is
+// relative and inside the kustomization root, errors name field
+func resolveInKustomizationRoot(dir string, field string, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("%s '%s' must be relative to the kustomization", field, path)
+	}
+
+	root := kustomizationRootDir(dir)
+	joined := filepath.Join(dir, path)
+	rel, err := filepath.Rel(root, joined)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s '%s' is outside of the kustomization root '%s'", field, path, root)
+	}
+
+	return joined, nil
+}
+
+// virtualRootDirPattern matches the names of virtual roots
+// from makeVirtualRootDir
+var virtualRootDirPattern = regexp.MustCompile(`^\.kustomization_[a-z]+_[0-9a-f]{16}$`)
+
+// kustomizationRootDir returns the root to restrict paths of the
+// kustomization in dir to. Virtual roots only exist in memory and
+// stand in for the directory they were created in, e.g. base_dir.
+func kustomizationRootDir(dir string) string {
+	if virtualRootDirPattern.MatchString(filepath.Base(dir)) {
+		return filepath.Dir(dir)
+	}
+
+	return dir
+}
+
+// helmChartValues merges valuesInline on top of the valuesFile
+func helmChartValues(fs fsPreprocessor, dir string, chart helmChart) ([]byte, error) {
+	values := make(map[interface{}]interface{})
+
+	if chart.ValuesFile != "" {
+		path, err := resolveInKustomizationRoot(dir, "valuesFile", chart.ValuesFile)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := fs.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading valuesFile failed: %s", err)
+		}
+
+		err = yaml.Unmarshal(data, &values)
+		if err != nil {
+			return nil, fmt.Errorf("parsing valuesFile '%s' failed: %s", chart.ValuesFile, err)
+		}
+	}
+
+	mergeValues(values, chart.ValuesInline)
+
+	return yaml.Marshal(values)
+}
+
+// mergeValues recursively merges src into dst, values from src
+// take precedence
+func mergeValues(dst map[interface{}]interface{}, src map[interface{}]interface{}) {
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[interface{}]interface{})
+		dstMap, dstIsMap := dst[k].(map[interface{}]interface{})
+		if srcIsMap && dstIsMap {
+			mergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// convertYaml converts generic yaml data into the typed value out
+func convertYaml(in interface{}, out interface{}) error {
+	if in == nil {
+		return nil
+	}
+
+	data, err := yaml.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(data, out)
+}
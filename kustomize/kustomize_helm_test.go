@@ -0,0 +1,177 @@
+package kustomize
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/resid"
+)
+
+// fakeHelm renders a ConfigMap named after the release, that records
+// the arguments and the values helm was called with
+const fakeHelm = `#!/bin/sh
+values=$(cat | base64 | tr -d '\n')
+cat <<MANIFEST
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: $2
+data:
+  args: "$*"
+  values: "$values"
+MANIFEST
+`
+
+func TestHelmPreprocessor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kustomize-helm")
+	if err != nil {
+		t.Fatalf("TestHelmPreprocessor: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	helmPath := filepath.Join(dir, "helm")
+	err = ioutil.WriteFile(helmPath, []byte(fakeHelm), 0755)
+	if err != nil {
+		t.Fatalf("TestHelmPreprocessor: %s", err)
+	}
+
+	fSys := makeBuildFileSys(filesys.MakeFsOnDisk(), &Config{HelmPath: helmPath}, nil)
+	rm, err := runKustomizeBuildWithFileSys(fSys, "../test_kustomizations/helm")
+	if err != nil {
+		t.Fatalf("TestHelmPreprocessor: %s", err)
+	}
+
+	id := resid.NewResIdWithNamespace(resid.Gvk{Version: "v1", Kind: "ConfigMap"}, "test-release", "test-helm")
+	r, err := rm.GetByCurrentId(id)
+	if err != nil {
+		t.Fatalf("TestHelmPreprocessor: %s", err)
+	}
+
+	data := r.Map()["data"].(map[string]interface{})
+
+	args := data["args"].(string)
+	for _, arg := range []string{"--namespace test-helm", "--include-crds", "--values -"} {
+		if !strings.Contains(args, arg) {
+			t.Errorf("TestHelmPreprocessor: missing argument, got: %s, want: %s.", args, arg)
+		}
+	}
+
+	rawValues, _ := base64.StdEncoding.DecodeString(data["values"].(string))
+	values := make(map[string]interface{})
+	yaml.Unmarshal(rawValues, &values)
+	if values["image"] != "nginx" {
+		t.Errorf("TestHelmPreprocessor: incorrect value from valuesFile, got: %v, want: %s.", values["image"], "nginx")
+	}
+	if values["replicas"] != 2 {
+		t.Errorf("TestHelmPreprocessor: incorrect value from valuesInline, got: %v, want: %d.", values["replicas"], 2)
+	}
+}
+
+func TestHelmPreprocessorChartNotFound(t *testing.T) {
+	fs := makeFsPreprocessor(filesys.MakeFsOnDisk())
+	chart := helmChart{Name: "missing", Repo: "https://charts.example.com"}
+
+	_, err := findLocalHelmChart(fs, "../test_kustomizations/helm", chart)
+	if err == nil {
+		t.Errorf("TestHelmPreprocessorChartNotFound: expected error for chart that is not available locally")
+	}
+}
+
+func TestHelmPreprocessorChartOnDiskOnly(t *testing.T) {
+	// in-memory files, e.g. the kustomization data source's files
+	memFs := filesys.MakeFsInMemory()
+	memFs.WriteFile("/files/charts/test/Chart.yaml", []byte("name: test"))
+	_, err := findLocalHelmChart(makeFsPreprocessor(memFs), "/files", helmChart{Name: "test"})
+	if err == nil || !strings.Contains(err.Error(), "only exists in memory") {
+		t.Errorf("TestHelmPreprocessorChartOnDiskOnly: expected error for in-memory chart, got: %v", err)
+	}
+
+	// inline files in the overlay of a template or overlay data source
+	dir, err := filepath.Abs("../test_kustomizations/helm")
+	if err != nil {
+		t.Fatalf("TestHelmPreprocessorChartOnDiskOnly: %s", err)
+	}
+	fs := makeFsPreprocessor(filesys.MakeFsOnDisk())
+	fs.overlay.WriteFile(filepath.Join(dir, "charts", "inline", "Chart.yaml"), []byte("name: inline"))
+	_, err = findLocalHelmChart(fs, dir, helmChart{Name: "inline"})
+	if err == nil || !strings.Contains(err.Error(), "only exists in memory") {
+		t.Errorf("TestHelmPreprocessorChartOnDiskOnly: expected error for overlay chart, got: %v", err)
+	}
+
+	got, err := findLocalHelmChart(fs, dir, helmChart{Name: "test"})
+	if err != nil {
+		t.Fatalf("TestHelmPreprocessorChartOnDiskOnly: %s", err)
+	}
+	if want := filepath.Join(dir, "charts", "test"); got != want {
+		t.Errorf("TestHelmPreprocessorChartOnDiskOnly: got: %s, want: %s.", got, want)
+	}
+}
+
+func TestHelmPreprocessorChartHomeRestrictions(t *testing.T) {
+	dir, err := filepath.Abs("../test_kustomizations/helm")
+	if err != nil {
+		t.Fatalf("TestHelmPreprocessorChartHomeRestrictions: %s", err)
+	}
+	fs := makeFsPreprocessor(filesys.MakeFsOnDisk())
+
+	cases := []struct {
+		name      string
+		chartHome string
+		want      string
+	}{
+		{"absolute", filepath.Join(dir, "charts"), "must be relative"},
+		{"outside root", "../basic/charts", "outside of the kustomization root"},
+		{"parent", "..", "outside of the kustomization root"},
+	}
+	for _, tc := range cases {
+		_, err := findLocalHelmChart(fs, dir, helmChart{Name: "test", ChartHome: tc.chartHome})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("TestHelmPreprocessorChartHomeRestrictions: %s: expected error containing '%s', got: %v", tc.name, tc.want, err)
+		}
+	}
+
+	// virtual roots of templates stand in for base_dir,
+	// their chartHome is rebased to be relative to them
+	virtualRoot := filepath.Join(dir, ".kustomization_template_0123456789abcdef")
+	got, err := findLocalHelmChart(fs, virtualRoot, helmChart{Name: "test", ChartHome: "../charts"})
+	if err != nil {
+		t.Fatalf("TestHelmPreprocessorChartHomeRestrictions: %s", err)
+	}
+	if want := filepath.Join(dir, "charts", "test"); got != want {
+		t.Errorf("TestHelmPreprocessorChartHomeRestrictions: got: %s, want: %s.", got, want)
+	}
+}
+
+func TestHelmPreprocessorValuesFileRestrictions(t *testing.T) {
+	dir, err := filepath.Abs("../test_kustomizations/helm")
+	if err != nil {
+		t.Fatalf("TestHelmPreprocessorValuesFileRestrictions: %s", err)
+	}
+	fs := makeFsPreprocessor(filesys.MakeFsOnDisk())
+
+	cases := []struct {
+		name       string
+		valuesFile string
+		want       string
+	}{
+		{"outside root", "../outside.yaml", "valuesFile '../outside.yaml' is outside of the kustomization root"},
+		{"absolute", filepath.Join(dir, "values.yaml"), "valuesFile '" + filepath.Join(dir, "values.yaml") + "' must be relative"},
+	}
+	for _, tc := range cases {
+		_, err := helmChartValues(fs, dir, helmChart{Name: "test", ValuesFile: tc.valuesFile})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("TestHelmPreprocessorValuesFileRestrictions: %s: expected error containing '%s', got: %v", tc.name, tc.want, err)
+		}
+	}
+
+	_, err = helmChartValues(fs, dir, helmChart{Name: "test", ValuesFile: "values.yaml"})
+	if err != nil {
+		t.Errorf("TestHelmPreprocessorValuesFileRestrictions: %s", err)
+	}
+}
@@ -0,0 +1,318 @@
+package kustomize
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// krmFunctionAnnotations hold the function spec of KRM function
+// configs, the second one is the legacy annotation
+var krmFunctionAnnotations = [...]string{
+	"config.kubernetes.io/function",
+	"config.k8s.io/function",
+}
+
+const krmTransformedFileName = "krm_transformed.yaml"
+
+// krmResourceList is the input and output of KRM functions
+type krmResourceList struct {
+	APIVersion     string                   `yaml:"apiVersion"`
+	Kind           string                   `yaml:"kind"`
+	Items          []map[string]interface{} `yaml:"items"`
+	FunctionConfig map[string]interface{}   `yaml:"functionConfig,omitempty"`
+	Results        []krmResult              `yaml:"results,omitempty"`
+}
+
+type krmResult struct {
+	Message  string `yaml:"message"`
+	Severity string `yaml:"severity"`
+}
+
+type krmFunctionSpec struct {
+	Exec *struct {
+		Path string `yaml:"path"`
+	} `yaml:"exec"`
+	Container *struct {
+		Image string `yaml:"image"`
+	} `yaml:"container"`
+}
+
+type krmFunction struct {
+	path   string
+	config map[string]interface{}
+}
+
+// krmExecPreprocessor runs exec KRM functions referenced in the
+// generators and transformers of a kustomization. Only binaries in
+// the allowlist can be run, and they only get the environment
+// variables in env passed through.
+//
+// Generators are run directly and their output is added to the
+// resources. To run transformers, the kustomization's directory is
+// built without them first, then the result is piped through the
+// transformers and replaces the kustomization.
+func krmExecPreprocessor(allowlist []string, env []string) kustomizationPreprocessor {
+	// dirs currently being built without their transformers
+	building := make(map[string]bool)
+
+	return func(fs fsPreprocessor, dir string, kustomization map[interface{}]interface{}) error {
+		generators, err := extractKRMFunctions(fs, dir, kustomization, "generators")
+		if err != nil {
+			return err
+		}
+		transformers, err := extractKRMFunctions(fs, dir, kustomization, "transformers")
+		if err != nil {
+			return err
+		}
+
+		if len(transformers) > 0 && !building[dir] {
+			building[dir] = true
+			rm, err := runKustomizeBuildWithFileSys(fs, dir)
+			delete(building, dir)
+			if err != nil {
+				return err
+			}
+
+			var items []map[string]interface{}
+			for _, r := range rm.Resources() {
+				items = append(items, r.Map())
+			}
+
+			for _, t := range transformers {
+				items, err = runKRMExecFunction(t, dir, items, allowlist, env)
+				if err != nil {
+					return err
+				}
+			}
+
+			// replace the kustomization with the transformed result
+			for k := range kustomization {
+				delete(kustomization, k)
+			}
+
+			return addKRMItemsToKustomization(fs, dir, kustomization, krmTransformedFileName, items)
+		}
+
+		for ix, g := range generators {
+			items, err := runKRMExecFunction(g, dir, nil, allowlist, env)
+			if err != nil {
+				return err
+			}
+
+			name := fmt.Sprintf("krm_generated_%d.yaml", ix)
+			err = addKRMItemsToKustomization(fs, dir, kustomization, name, items)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// extractKRMFunctions removes KRM function configs from the
+// generators or transformers of a kustomization and returns them
+func extractKRMFunctions(fs fsPreprocessor, dir string, kustomization map[interface{}]interface{}, key string) (functions []krmFunction, err error) {
+	entries, ok := kustomization[key].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	var remaining []interface{}
+	for _, e := range entries {
+		path, ok := e.(string)
+		if !ok {
+			remaining = append(remaining, e)
+			continue
+		}
+
+		data, err := fs.FsOverlay.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			// leave reporting missing files to kustomize
+			remaining = append(remaining, e)
+			continue
+		}
+
+		config := make(map[string]interface{})
+		err = yaml.Unmarshal(data, &config)
+		if err != nil {
+			remaining = append(remaining, e)
+			continue
+		}
+
+		spec, ok, err := krmFunctionSpecFromConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("%s '%s': %s", key, path, err)
+		}
+		if !ok {
+			remaining = append(remaining, e)
+			continue
+		}
+
+		if spec.Exec == nil || spec.Exec.Path == "" {
+			return nil, fmt.Errorf("%s '%s': only exec KRM functions are supported", key, path)
+		}
+
+		functions = append(functions, krmFunction{
+			path:   spec.Exec.Path,
+			config: config,
+		})
+	}
+
+	if len(remaining) == 0 {
+		delete(kustomization, key)
+	} else {
+		kustomization[key] = remaining
+	}
+
+	return functions, nil
+}
+
+func krmFunctionSpecFromConfig(config map[string]interface{}) (spec krmFunctionSpec, ok bool, err error) {
+	var meta struct {
+		Metadata struct {
+			Annotations map[string]string `yaml:"annotations"`
+		} `yaml:"metadata"`
+	}
+	err = convertYaml(config, &meta)
+	if err != nil {
+		return spec, false, err
+	}
+
+	for _, a := range krmFunctionAnnotations {
+		value, found := meta.Metadata.Annotations[a]
+		if !found {
+			continue
+		}
+
+		err = yaml.Unmarshal([]byte(value), &spec)
+		if err != nil {
+			return spec, false, fmt.Errorf("invalid annotation '%s': %s", a, err)
+		}
+
+		return spec, true, nil
+	}
+
+	return spec, false, nil
+}
+
+// resolveKRMExecPath returns the absolute path of an exec function
+// if it is in the allowlist
+func resolveKRMExecPath(path string, dir string, allowlist []string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path = filepath.Clean(path)
+
+	for _, allowed := range allowlist {
+		abs, err := filepath.Abs(allowed)
+		if err != nil {
+			continue
+		}
+		if abs == path {
+			return path, nil
+		}
+	}
+
+	if len(allowlist) == 0 {
+		return "", fmt.Errorf("exec KRM function '%s' can not be run, exec functions are disabled, add it to exec_function_allowlist to enable it", path)
+	}
+
+	return "", fmt.Errorf("exec KRM function '%s' is not in exec_function_allowlist", path)
+}
+
+func runKRMExecFunction(fn krmFunction, dir string, items []map[string]interface{}, allowlist []string, env []string) ([]map[string]interface{}, error) {
+	path, err := resolveKRMExecPath(fn.path, dir, allowlist)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := yaml.Marshal(krmResourceList{
+		APIVersion:     "config.kubernetes.io/v1",
+		Kind:           "ResourceList",
+		Items:          items,
+		FunctionConfig: fn.config,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path)
+	if _, err := os.Stat(dir); err == nil {
+		cmd.Dir = dir
+	}
+	cmd.Env = passthroughEnv(env)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("running exec KRM function '%s' failed: %s: %s", path, err, stderr.String())
+	}
+
+	var output krmResourceList
+	err = yaml.Unmarshal(stdout.Bytes(), &output)
+	if err != nil {
+		return nil, fmt.Errorf("parsing output of exec KRM function '%s' failed: %s", path, err)
+	}
+
+	var errs []string
+	for _, r := range output.Results {
+		if r.Severity == "error" {
+			errs = append(errs, r.Message)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("exec KRM function '%s' failed: %s", path, strings.Join(errs, ", "))
+	}
+
+	return output.Items, nil
+}
+
+// passthroughEnv returns the environment for exec KRM functions,
+// it is never nil, because exec.Cmd inherits the provider's whole
+// environment if Env is nil
+func passthroughEnv(names []string) []string {
+	env := []string{}
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+
+	return env
+}
+
+// addKRMItemsToKustomization writes items as a yaml stream to the
+// overlay and adds the file to the kustomization's resources
+func addKRMItemsToKustomization(fs fsPreprocessor, dir string, kustomization map[interface{}]interface{}, name string, items []map[string]interface{}) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	var docs [][]byte
+	for _, item := range items {
+		doc, err := yaml.Marshal(item)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+	}
+
+	err := fs.overlay.WriteFile(filepath.Join(dir, name), bytes.Join(docs, []byte("---\n")))
+	if err != nil {
+		return err
+	}
+
+	appendKustomizationResource(kustomization, name)
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package kustomize
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/resid"
+)
+
+// fakeKRMGenerator generates a ConfigMap recording
+// an environment variable
+const fakeKRMGenerator = `#!/bin/sh
+cat > /dev/null
+cat <<RESOURCELIST
+apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: generated
+  data:
+    passed: "$TEST_KRM_PASSED"
+    blocked: "$TEST_KRM_BLOCKED"
+RESOURCELIST
+`
+
+// fakeKRMTransformer scales all replicas to 3
+const fakeKRMTransformer = `#!/bin/sh
+sed 's/replicas: 1/replicas: 3/'
+`
+
+const krmTestKustomization = `
+namespace: test-krm
+resources:
+- deployment.yaml
+generators:
+- generator.yaml
+transformers:
+- transformer.yaml
+`
+
+const krmTestDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test
+spec:
+  replicas: 1
+`
+
+const krmTestFunctionConfig = `
+apiVersion: example.com/v1
+kind: %s
+metadata:
+  name: test
+  annotations:
+    config.kubernetes.io/function: |
+      exec:
+        path: %s
+`
+
+func writeKRMTestFunctions(t *testing.T) (dir string, generator string, transformer string) {
+	dir, err := ioutil.TempDir("", "kustomize-krm")
+	if err != nil {
+		t.Fatalf("writeKRMTestFunctions: %s", err)
+	}
+
+	generator = filepath.Join(dir, "generator")
+	err = ioutil.WriteFile(generator, []byte(fakeKRMGenerator), 0755)
+	if err != nil {
+		t.Fatalf("writeKRMTestFunctions: %s", err)
+	}
+
+	transformer = filepath.Join(dir, "transformer")
+	err = ioutil.WriteFile(transformer, []byte(fakeKRMTransformer), 0755)
+	if err != nil {
+		t.Fatalf("writeKRMTestFunctions: %s", err)
+	}
+
+	return dir, generator, transformer
+}
+
+func makeKRMTestFs(generator string, transformer string) filesys.FileSystem {
+	fSys := filesys.MakeFsInMemory()
+	fSys.WriteFile("/app/kustomization.yaml", []byte(krmTestKustomization))
+	fSys.WriteFile("/app/deployment.yaml", []byte(krmTestDeployment))
+	fSys.WriteFile("/app/generator.yaml", []byte(fmt.Sprintf(krmTestFunctionConfig, "Generator", generator)))
+	fSys.WriteFile("/app/transformer.yaml", []byte(fmt.Sprintf(krmTestFunctionConfig, "Transformer", transformer)))
+
+	return fSys
+}
+
+func TestKRMExecPreprocessor(t *testing.T) {
+	dir, generator, transformer := writeKRMTestFunctions(t)
+	defer os.RemoveAll(dir)
+
+	os.Setenv("TEST_KRM_PASSED", "passed")
+	os.Setenv("TEST_KRM_BLOCKED", "blocked")
+	defer os.Unsetenv("TEST_KRM_PASSED")
+	defer os.Unsetenv("TEST_KRM_BLOCKED")
+
+	config := &Config{
+		ExecFunctionAllowlist: []string{generator, transformer},
+		ExecFunctionEnv:       []string{"TEST_KRM_PASSED"},
+	}
+	fSys := makeBuildFileSys(makeKRMTestFs(generator, transformer), config, nil)
+
+	rm, err := runKustomizeBuildWithFileSys(fSys, "/app")
+	if err != nil {
+		t.Fatalf("TestKRMExecPreprocessor: %s", err)
+	}
+
+	cmID := resid.NewResIdWithNamespace(resid.Gvk{Version: "v1", Kind: "ConfigMap"}, "generated", "test-krm")
+	cm, err := rm.GetByCurrentId(cmID)
+	if err != nil {
+		t.Fatalf("TestKRMExecPreprocessor: %s", err)
+	}
+
+	data := cm.Map()["data"].(map[string]interface{})
+	if data["passed"] != "passed" {
+		t.Errorf("TestKRMExecPreprocessor: env not passed through, got: %s, want: %s.", data["passed"], "passed")
+	}
+	if data["blocked"] != "" {
+		t.Errorf("TestKRMExecPreprocessor: env not in passthrough list, got: %s, want: %s.", data["blocked"], "")
+	}
+
+	depID := resid.NewResIdWithNamespace(resid.Gvk{Group: "apps", Version: "v1", Kind: "Deployment"}, "test", "test-krm")
+	dep, err := rm.GetByCurrentId(depID)
+	if err != nil {
+		t.Fatalf("TestKRMExecPreprocessor: %s", err)
+	}
+
+	replicas := fmt.Sprint(dep.Map()["spec"].(map[string]interface{})["replicas"])
+	if replicas != "3" {
+		t.Errorf("TestKRMExecPreprocessor: transformer not applied, got: %s, want: %s.", replicas, "3")
+	}
+}
+
+func TestKRMExecPreprocessorNotAllowed(t *testing.T) {
+	dir, generator, transformer := writeKRMTestFunctions(t)
+	defer os.RemoveAll(dir)
+
+	config := &Config{
+		ExecFunctionAllowlist: []string{generator},
+	}
+	fSys := makeBuildFileSys(makeKRMTestFs(generator, transformer), config, nil)
+
+	_, err := runKustomizeBuildWithFileSys(fSys, "/app")
+	if err == nil || !strings.Contains(err.Error(), "not in exec_function_allowlist") {
+		t.Errorf("TestKRMExecPreprocessorNotAllowed: expected allowlist error, got: %v", err)
+	}
+}
+
+func TestKRMExecPreprocessorNoPassthroughEnv(t *testing.T) {
+	dir, generator, transformer := writeKRMTestFunctions(t)
+	defer os.RemoveAll(dir)
+
+	os.Setenv("TEST_KRM_PASSED", "passed")
+	os.Setenv("TEST_KRM_BLOCKED", "blocked")
+	defer os.Unsetenv("TEST_KRM_PASSED")
+	defer os.Unsetenv("TEST_KRM_BLOCKED")
+
+	config := &Config{
+		ExecFunctionAllowlist: []string{generator, transformer},
+	}
+	fSys := makeBuildFileSys(makeKRMTestFs(generator, transformer), config, nil)
+
+	rm, err := runKustomizeBuildWithFileSys(fSys, "/app")
+	if err != nil {
+		t.Fatalf("TestKRMExecPreprocessorNoPassthroughEnv: %s", err)
+	}
+
+	cmID := resid.NewResIdWithNamespace(resid.Gvk{Version: "v1", Kind: "ConfigMap"}, "generated", "test-krm")
+	cm, err := rm.GetByCurrentId(cmID)
+	if err != nil {
+		t.Fatalf("TestKRMExecPreprocessorNoPassthroughEnv: %s", err)
+	}
+
+	data := cm.Map()["data"].(map[string]interface{})
+	for _, key := range []string{"passed", "blocked"} {
+		if data[key] != "" {
+			t.Errorf("TestKRMExecPreprocessorNoPassthroughEnv: env passed through without passthrough list, got: %s, want: %s.", data[key], "")
+		}
+	}
+}
@@ -1,9 +1,15 @@
 package kustomize
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 	"sigs.k8s.io/kustomize/api/filesys"
@@ -13,49 +19,130 @@ import (
 
 var _ filesys.FileSystem = FsOverlay{}
 
-// FsOverlay implements FileSystem using the local filesystem.
+// FsOverlay implements FileSystem as the union of an in-memory
+// overlay on top of a base filesystem. Paths in the overlay take
+// precedence and all writes go to the overlay. The base is never
+// modified, removed base paths are hidden by whiteouts.
 type FsOverlay struct {
 	base filesys.FileSystem
 	overlay filesys.FileSystem
+	// whiteouts are removed paths, that hide the
+	// path and everything below it in the base
+	whiteouts map[string]bool
 	rootDir string
+	baseDir string
+	// detectInlineContent treats strings that can not
+	// be loaded as paths as inline content
+	detectInlineContent bool
 }
 
-// MakefsOverlay makes an instance of FsOverlay.
-func MakefsOverlay() (FsOverlay, error) {
-	curDir, err := os.Getwd(); if err != nil {
+// MakefsOverlay makes an instance of FsOverlay with its own virtual
+// root directory inside baseDir, that only exists in the overlay.
+// Relative paths passed to AddOverlayFiles resolve against baseDir,
+// which defaults to the current working directory.
+func MakefsOverlay(baseDir string) (FsOverlay, error) {
+	if baseDir == "" {
+		curDir, err := os.Getwd(); if err != nil {
+			return FsOverlay{}, err
+		}
+		baseDir = curDir
+	}
+	baseDir, err := filepath.Abs(baseDir); if err != nil {
+		return FsOverlay{}, err
+	}
+
+	rootDir, err := makeVirtualRootDir(baseDir, ".kustomization_template"); if err != nil {
 		return FsOverlay{}, err
 	}
+
+	fs := makeFsOverlayOn(filesys.MakeFsOnDisk(), rootDir)
+	fs.baseDir = baseDir
+	err = fs.overlay.MkdirAll(rootDir); if err != nil {
+		return FsOverlay{}, err
+	}
+	return fs, nil
+}
+
+// makeVirtualRootDir returns a unique directory name inside baseDir,
+// for virtual roots that only exist in an overlay
+func makeVirtualRootDir(baseDir string, prefix string) (string, error) {
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(baseDir, fmt.Sprintf("%s_%s", prefix, hex.EncodeToString(id))), nil
+}
+
+// makeFsOverlayOn makes an instance of FsOverlay using
+// the given filesystem as its base.
+func makeFsOverlayOn(base filesys.FileSystem, rootDir string) FsOverlay {
 	return FsOverlay{
 		overlay: filesys.MakeFsInMemory(),
-		base: filesys.MakeFsOnDisk(),
-		rootDir: curDir,
-	}, nil
+		base: base,
+		whiteouts: make(map[string]bool),
+		rootDir: rootDir,
+	}
 }
 
-// Create delegates to os.Create.
+// Create creates the file in the overlay.
 func (fs FsOverlay) Create(name string) (filesys.File, error) {
-	return fs.base.Create(name)
+	return fs.overlay.Create(name)
 }
 
-// Mkdir delegates to os.Mkdir.
+// Mkdir creates the directory in the overlay.
 func (fs FsOverlay) Mkdir(name string) error {
-	return fs.base.Mkdir(name)
+	return fs.overlay.Mkdir(name)
 }
 
-// MkdirAll delegates to os.MkdirAll.
+// MkdirAll creates the directory and its parents in the overlay.
 func (fs FsOverlay) MkdirAll(name string) error {
-	return fs.base.MkdirAll(name)
+	return fs.overlay.MkdirAll(name)
 }
 
-// RemoveAll delegates to os.RemoveAll.
+// RemoveAll removes the path from the overlay and records a whiteout
+// to hide it in the base, the base itself is never modified.
 func (fs FsOverlay) RemoveAll(name string) error {
-	return fs.base.RemoveAll(name)
+	if !fs.Exists(name) {
+		return fmt.Errorf("cannot find '%s' to remove it", name)
+	}
+	if fs.overlay.Exists(name) {
+		err := fs.overlay.RemoveAll(name); if err != nil {
+			return err
+		}
+	}
+	if fs.inBase(name) {
+		fs.whiteouts[filepath.Clean(name)] = true
+	}
+	return nil
 }
 
-// Open delegates to os.Open.
+// isWhiteout returns true if name or one of its parents was removed
+func (fs FsOverlay) isWhiteout(name string) bool {
+	for p := filepath.Clean(name); ; p = filepath.Dir(p) {
+		if fs.whiteouts[p] {
+			return true
+		}
+		if p == filepath.Dir(p) {
+			return false
+		}
+	}
+}
+
+// inBase returns true if name exists in the base and is not removed
+func (fs FsOverlay) inBase(name string) bool {
+	return !fs.isWhiteout(name) && fs.base.Exists(name)
+}
+
+// Open opens the file from the overlay if it exists there,
+// otherwise from the base.
 func (fs FsOverlay) Open(name string) (filesys.File, error) {
-	f, err := fs.overlay.Open(name); if err == nil {
-		return f, nil
+	if fs.overlay.Exists(name) {
+		return fs.overlay.Open(name)
+	}
+	if fs.isWhiteout(name) {
+		return nil, fmt.Errorf("cannot find '%s' to open it: %w", name, os.ErrNotExist)
 	}
 	return fs.base.Open(name)
 }
@@ -69,91 +156,438 @@ func (fs FsOverlay) CleanedAbs(path string) (filesys.ConfirmedDir, string, error
 	if fs.overlay.Exists(path) {
 		return fs.overlay.CleanedAbs(path)
 	}
+	if fs.isWhiteout(path) {
+		return "", "", fmt.Errorf("cannot find '%s': %w", path, os.ErrNotExist)
+	}
 	return fs.base.CleanedAbs(path)
 }
 
-// Exists returns true if os.Stat succeeds.
+// Exists returns true if the path exists in the overlay or the base.
 func (fs FsOverlay) Exists(name string) bool {
-	return fs.overlay.Exists(name) || fs.base.Exists(name)
+	return fs.overlay.Exists(name) || fs.inBase(name)
 }
 
-// Glob returns the list of matching files
+// Glob returns the sorted list of files matching in
+// the overlay or the base, without duplicates.
 func (fs FsOverlay) Glob(pattern string) ([]string, error) {
+	// the in-memory filesystem does not validate patterns
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	resOverlay, err := fs.overlay.Glob(pattern); if err != nil {
+		return nil, err
+	}
+	resBase, err := fs.base.Glob(pattern); if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
 	var m []string
-	resOverlay, errOverlay := fs.overlay.Glob(pattern); if errOverlay == nil {
-		m = append(m, resOverlay...)
+	for _, match := range resBase {
+		// mark removed base paths as seen to skip them
+		if fs.isWhiteout(match) {
+			seen[match] = true
+		}
 	}
-	resBase, errBase := fs.base.Glob(pattern); if errBase == nil {
-		m = append(m, resBase...)
+	for _, match := range append(resOverlay, resBase...) {
+		if !seen[match] {
+			seen[match] = true
+			m = append(m, match)
+		}
 	}
+	sort.Strings(m)
 	return m, nil
 }
 
-// IsDir delegates to os.Stat and FileInfo.IsDir
+// IsDir returns true if the path is a directory in the overlay,
+// or if it does not exist in the overlay, in the base.
 func (fs FsOverlay) IsDir(name string) bool {
-	return fs.base.IsDir(name)
+	if fs.overlay.Exists(name) {
+		return fs.overlay.IsDir(name)
+	}
+	return !fs.isWhiteout(name) && fs.base.IsDir(name)
 }
 
-// ReadFile delegates to ioutil.ReadFile.
+// ReadFile reads the file from the overlay if it exists there,
+// otherwise from the base.
 func (fs FsOverlay) ReadFile(name string) ([]byte, error) {
 	if fs.overlay.Exists(name) {
 		return fs.overlay.ReadFile(name)
 	}
+	if fs.isWhiteout(name) {
+		return nil, fmt.Errorf("cannot find '%s' to read it: %w", name, os.ErrNotExist)
+	}
 	return fs.base.ReadFile(name)
 }
 
-// WriteFile delegates to ioutil.WriteFile with read/write permissions.
+// WriteFile writes the file to the overlay, the base is never modified.
 func (fs FsOverlay) WriteFile(name string, c []byte) error {
-	return fs.base.WriteFile(name, c)
+	return fs.overlay.WriteFile(name, c)
 }
 
-// Walk delegates to filepath.Walk.
+// Walk walks the union of the overlay and the base in lexical order,
+// like filepath.Walk. Paths existing in both are visited once, using
+// the FileInfo from the overlay.
 func (fs FsOverlay) Walk(path string, walkFn filepath.WalkFunc) error {
-	_ = fs.overlay.Walk(path, walkFn)
-	_ = fs.overlay.Walk(path, walkFn)
+	if !fs.Exists(path) {
+		err := walkFn(path, nil, fmt.Errorf("cannot find '%s' to walk it: %w", path, os.ErrNotExist))
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	// errors reading paths are kept, to pass them to walkFn
+	// when visiting the path, like filepath.Walk does
+	type walkEntry struct {
+		info os.FileInfo
+		err  error
+	}
+	entries := make(map[string]walkEntry)
+	collect := func(p string, info os.FileInfo, err error) error {
+		e, ok := entries[p]
+		if !ok {
+			entries[p] = walkEntry{info, err}
+			return nil
+		}
+		if e.info == nil {
+			e.info = info
+		}
+		if e.err == nil {
+			e.err = err
+		}
+		entries[p] = e
+		return nil
+	}
+	if fs.overlay.Exists(path) {
+		err := fs.overlay.Walk(path, collect)
+		if err != nil {
+			return err
+		}
+	}
+	if fs.inBase(path) {
+		err := fs.base.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if fs.isWhiteout(p) {
+				return nil
+			}
+			return collect(p, info, err)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	paths := make([]string, 0, len(entries))
+	for p := range entries {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return lessPath(paths[i], paths[j])
+	})
+
+	var skip []string
+	for _, p := range paths {
+		if isSkipped(p, skip) {
+			continue
+		}
+
+		e := entries[p]
+		err := walkFn(p, e.info, e.err)
+		if err == filepath.SkipDir {
+			if e.info != nil && e.info.IsDir() {
+				// skip the directory's content
+				skip = append(skip, p)
+			} else {
+				// skip the remaining files in the directory
+				skip = append(skip, filepath.Dir(p))
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (fs FsOverlay) AddOverlayFiles(prefix string, specOrNames []interface{}) ([]string, error) {
-	ldr, err := fLdr.NewLoader(fLdr.RestrictionRootOnly, ".", fs.base)
-	if err != nil {
-		return nil, err
+// lessPath orders paths like filepath.Walk visits them,
+// comparing them element by element
+func lessPath(a string, b string) bool {
+	as := strings.Split(filepath.ToSlash(a), "/")
+	bs := strings.Split(filepath.ToSlash(b), "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return as[i] < bs[i]
+		}
 	}
-	defer ldr.Cleanup()
+	return len(as) < len(bs)
+}
+
+// isSkipped returns true if p is below any of the skipped directories
+func isSkipped(p string, skip []string) bool {
+	for _, dir := range skip {
+		if strings.HasPrefix(p, dir+string(filepath.Separator)) || (dir == string(filepath.Separator) && p != dir) {
+			return true
+		}
+	}
+	return false
+}
 
+func (fs FsOverlay) AddOverlayFiles(prefix string, specOrNames []interface{}) ([]string, error) {
 	names := make([]string, len(specOrNames))
 
 	for ix, specOrName := range specOrNames {
 		name := fmt.Sprintf("%s_%d", prefix, ix)
-		switch specOrName.(type) {
-		case string:
-			specOrNameStr := specOrName.(string)
-			_, loadErr := ldr.New(specOrNameStr)
-
-			// If kustomize can load than it is a valid file else treat as data
-			if loadErr == nil {
-				names[ix] = specOrNameStr
-			} else {
-				names[ix] = name
-				err = fs.AddOverlayFile(name, []byte(specOrNameStr)); if err != nil {
-					return names, err
-				}
-			}
-			break
-		case map[interface{}]interface{}:
-			spec, err := toYaml(specOrName.(map[interface{}]interface{})); if err != nil {
-				return names, err
+		resolved, err := fs.AddOverlayPathOrContent(name, specOrName); if err != nil {
+			return names, err
+		}
+		names[ix] = resolved
+	}
+	return names, nil
+}
+
+// inlineSourceTypes are the keys of explicitly typed sources,
+// e.g. {path = "..."}, {content = "..."} or {manifest = {...}}
+var inlineSourceTypes = [...]string{"path", "content", "manifest"}
+
+// AddOverlayPathOrContent returns the path to use in the kustomization
+// for specOrName. Strings are paths relative to baseDir, or remote
+// targets. Typed sources are resolved by their type, and other maps or
+// lists are inline yaml. Inline content is added to the overlay as name.
+func (fs FsOverlay) AddOverlayPathOrContent(name string, specOrName interface{}) (string, error) {
+	return fs.addOverlaySource(name, specOrName, nil)
+}
+
+// AddOverlayResources is like AddOverlayPathOrContent, but expands
+// inline multi document streams and lists into one document per
+// resource, see expandResourceDocuments.
+func (fs FsOverlay) AddOverlayResources(name string, specOrName interface{}) (string, error) {
+	return fs.addOverlaySource(name, specOrName, expandResourceDocuments)
+}
+
+// addOverlaySource resolves specOrName, inline content is passed
+// through normalize, if set, before it is added to the overlay
+func (fs FsOverlay) addOverlaySource(name string, specOrName interface{}, normalize func([]byte) ([]byte, error)) (string, error) {
+	switch v := specOrName.(type) {
+	case string:
+		return fs.addOverlayPath(name, v, normalize)
+	case map[interface{}]interface{}:
+		sourceType, source, ok, err := typedInlineSource(v); if err != nil {
+			return name, err
+		}
+		if !ok {
+			return fs.addOverlayManifest(name, v, normalize)
+		}
+
+		switch sourceType {
+		case "path":
+			path, ok := source.(string)
+			if !ok {
+				return name, fmt.Errorf("path must be a string, got: %T", source)
 			}
-			names[ix] = name
-			err = fs.AddOverlayFile(name, spec); if err != nil {
-				return names, err
+			return fs.addOverlayPath(name, path, normalize)
+		case "content":
+			content, ok := source.(string)
+			if !ok {
+				return name, fmt.Errorf("content must be a string, got: %T, use manifest for yaml maps", source)
 			}
-			break
+			return fs.addOverlayContent(name, []byte(content), normalize)
 		default:
-			return names, fmt.Errorf("unsupported type: %T", specOrName)
+			return fs.addOverlayManifest(name, source, normalize)
 		}
+	case []interface{}:
+		return fs.addOverlayManifest(name, v, normalize)
+	default:
+		return name, fmt.Errorf("unsupported type: %T", specOrName)
 	}
-	return names, nil
+}
+
+// addOverlayPath resolves path against baseDir, or as remote target
+// kustomize can load. If detectInlineContent is set, paths that can not
+// be loaded are treated as inline content instead.
+func (fs FsOverlay) addOverlayPath(name string, path string, normalize func([]byte) ([]byte, error)) (string, error) {
+	resolved, ok, err := fs.resolveBasePath(name, path); if err != nil {
+		return name, err
+	}
+	if ok {
+		return resolved, nil
+	}
+
+	ldr, err := fLdr.NewLoader(fLdr.RestrictionRootOnly, fs.baseDir, fs.base)
+	if err != nil {
+		return name, err
+	}
+	defer ldr.Cleanup()
+
+	// If kustomize can load than it is a valid file else treat as data
+	_, loadErr := ldr.New(path)
+	if loadErr == nil {
+		return path, nil
+	}
+
+	if fs.detectInlineContent {
+		return fs.addOverlayContent(name, []byte(path), normalize)
+	}
+
+	return name, fmt.Errorf(
+		"'%s' is not a file or directory in '%s' and can not be loaded as remote target: %s. Use {content = ...} or {manifest = {...}} for inline content",
+		path, fs.baseDir, loadErr)
+}
+
+func (fs FsOverlay) addOverlayManifest(name string, manifest interface{}, normalize func([]byte) ([]byte, error)) (string, error) {
+	switch manifest.(type) {
+	case map[interface{}]interface{}, []interface{}:
+	default:
+		return name, fmt.Errorf("manifest must be a map or list, got: %T", manifest)
+	}
+
+	data, err := yaml.Marshal(manifest); if err != nil {
+		return name, err
+	}
+	return fs.addOverlayContent(name, data, normalize)
+}
+
+func (fs FsOverlay) addOverlayContent(name string, data []byte, normalize func([]byte) ([]byte, error)) (string, error) {
+	if normalize != nil {
+		var err error
+		data, err = normalize(data); if err != nil {
+			return name, err
+		}
+	}
+	return name, fs.AddOverlayFile(name, data)
+}
+
+// expandResourceDocuments splits a yaml or json stream into one document
+// per resource. Lists, e.g. kind: List or json arrays, are expanded into
+// their items and empty documents are dropped. Errors name the index of
+// the failing document.
+func expandResourceDocuments(data []byte) ([]byte, error) {
+	var docs [][]byte
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for ix := 0; ; ix++ {
+		var doc interface{}
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %s", ix, err)
+		}
+
+		resources, err := expandResourceItems(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %s", ix, err)
+		}
+
+		for _, r := range resources {
+			out, err := yaml.Marshal(r)
+			if err != nil {
+				return nil, fmt.Errorf("document %d: %s", ix, err)
+			}
+			docs = append(docs, out)
+		}
+	}
+
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+// expandResourceItems returns the resources in doc,
+// recursively expanding lists
+func expandResourceItems(doc interface{}) ([]map[interface{}]interface{}, error) {
+	var items []interface{}
+
+	switch v := doc.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		items = v
+	case map[interface{}]interface{}:
+		kind, _ := v["kind"].(string)
+		list, isList := v["items"].([]interface{})
+		if !strings.HasSuffix(kind, "List") || !isList {
+			return []map[interface{}]interface{}{v}, nil
+		}
+		items = list
+	default:
+		return nil, fmt.Errorf("expected a resource or a list of resources, got: %T", doc)
+	}
+
+	var resources []map[interface{}]interface{}
+	for ix, item := range items {
+		expanded, err := expandResourceItems(item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %s", ix, err)
+		}
+		resources = append(resources, expanded...)
+	}
+
+	return resources, nil
+}
+
+// typedInlineSource returns the type and value of explicitly
+// typed sources, ok is false for other maps
+func typedInlineSource(spec map[interface{}]interface{}) (sourceType string, source interface{}, ok bool, err error) {
+	var found []string
+	for _, t := range inlineSourceTypes {
+		if _, exists := spec[t]; exists {
+			found = append(found, t)
+		}
+	}
+
+	if len(found) == 0 {
+		return "", nil, false, nil
+	}
+	if len(found) > 1 || len(spec) > 1 {
+		var keys []string
+		for k := range spec {
+			keys = append(keys, fmt.Sprint(k))
+		}
+		sort.Strings(keys)
+		return "", nil, false, fmt.Errorf("typed source must have exactly one of %s, got: %s", strings.Join(inlineSourceTypes[:], ", "), strings.Join(keys, ", "))
+	}
+
+	return found[0], spec[found[0]], true, nil
+}
+
+// resolveBasePath checks if path exists relative to baseDir.
+// Directories are rebased to be relative to the virtual root.
+// Files are copied into the overlay below the virtual root,
+// keeping their name, because kustomize only loads files
+// inside the root.
+func (fs FsOverlay) resolveBasePath(prefix string, path string) (string, bool, error) {
+	if fs.baseDir == "" {
+		return path, false, nil
+	}
+
+	absPath := path
+	if !filepath.IsAbs(absPath) {
+		absPath = filepath.Join(fs.baseDir, path)
+	}
+
+	if fs.base.IsDir(absPath) {
+		rel, err := filepath.Rel(fs.rootDir, absPath)
+		return rel, err == nil, err
+	}
+
+	if !fs.base.Exists(absPath) {
+		return path, false, nil
+	}
+
+	data, err := fs.base.ReadFile(absPath)
+	if err != nil {
+		return path, false, err
+	}
+
+	name := filepath.Join(prefix, filepath.Base(absPath))
+	err = fs.AddOverlayFile(name, data)
+	if err != nil {
+		return path, false, err
+	}
+
+	return name, true, nil
 }
 
 func (fs FsOverlay) AddOverlayFile(name string, data []byte) error {
@@ -0,0 +1,498 @@
+package kustomize
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+func makeTestFsOverlay() FsOverlay {
+	base := filesys.MakeFsInMemory()
+	base.WriteFile("/app/a.yaml", []byte("base a"))
+	base.WriteFile("/app/b.yaml", []byte("base b"))
+	base.WriteFile("/app/sub/c.yaml", []byte("base c"))
+	base.WriteFile("/app/file-or-dir", []byte("base file"))
+
+	fs := makeFsOverlayOn(base, "")
+	fs.overlay.WriteFile("/app/b.yaml", []byte("overlay b"))
+	fs.overlay.WriteFile("/app/d.yaml", []byte("overlay d"))
+	fs.overlay.WriteFile("/app/sub/e.yaml", []byte("overlay e"))
+	fs.overlay.WriteFile("/app/file-or-dir/f.yaml", []byte("overlay f"))
+
+	return fs
+}
+
+func TestFsOverlayReadFile(t *testing.T) {
+	fs := makeTestFsOverlay()
+
+	cases := map[string]string{
+		"/app/a.yaml":     "base a",
+		"/app/b.yaml":     "overlay b",
+		"/app/d.yaml":     "overlay d",
+		"/app/sub/c.yaml": "base c",
+		"/app/sub/e.yaml": "overlay e",
+	}
+
+	for name, want := range cases {
+		got, err := fs.ReadFile(name)
+		if err != nil {
+			t.Errorf("TestFsOverlayReadFile: %s: %s", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("TestFsOverlayReadFile: %s, got: %s, want: %s.", name, got, want)
+		}
+	}
+
+	if _, err := fs.ReadFile("/app/missing.yaml"); err == nil {
+		t.Errorf("TestFsOverlayReadFile: expected error for missing file")
+	}
+}
+
+func TestFsOverlayExistsIsDir(t *testing.T) {
+	fs := makeTestFsOverlay()
+
+	cases := []struct {
+		name   string
+		exists bool
+		isDir  bool
+	}{
+		{"/app", true, true},
+		{"/app/a.yaml", true, false},
+		{"/app/d.yaml", true, false},
+		{"/app/sub", true, true},
+		// the overlay takes precedence
+		{"/app/file-or-dir", true, true},
+		{"/app/missing", false, false},
+	}
+
+	for _, c := range cases {
+		if got := fs.Exists(c.name); got != c.exists {
+			t.Errorf("TestFsOverlayExistsIsDir: Exists(%s), got: %t, want: %t.", c.name, got, c.exists)
+		}
+		if got := fs.IsDir(c.name); got != c.isDir {
+			t.Errorf("TestFsOverlayExistsIsDir: IsDir(%s), got: %t, want: %t.", c.name, got, c.isDir)
+		}
+	}
+}
+
+func TestFsOverlayGlob(t *testing.T) {
+	fs := makeTestFsOverlay()
+
+	got, err := fs.Glob("/app/*.yaml")
+	if err != nil {
+		t.Fatalf("TestFsOverlayGlob: %s", err)
+	}
+
+	want := []string{"/app/a.yaml", "/app/b.yaml", "/app/d.yaml"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TestFsOverlayGlob: got: %s, want: %s.", got, want)
+	}
+
+	if _, err := fs.Glob("/app/["); err == nil {
+		t.Errorf("TestFsOverlayGlob: expected error for malformed pattern")
+	}
+}
+
+func TestFsOverlayWrites(t *testing.T) {
+	fs := makeTestFsOverlay()
+
+	err := fs.WriteFile("/app/a.yaml", []byte("written a"))
+	if err != nil {
+		t.Fatalf("TestFsOverlayWrites: %s", err)
+	}
+	err = fs.MkdirAll("/app/new/dir")
+	if err != nil {
+		t.Fatalf("TestFsOverlayWrites: %s", err)
+	}
+
+	got, _ := fs.ReadFile("/app/a.yaml")
+	if string(got) != "written a" {
+		t.Errorf("TestFsOverlayWrites: got: %s, want: %s.", got, "written a")
+	}
+
+	base, _ := fs.base.ReadFile("/app/a.yaml")
+	if string(base) != "base a" {
+		t.Errorf("TestFsOverlayWrites: base modified, got: %s, want: %s.", base, "base a")
+	}
+	if fs.base.Exists("/app/new/dir") || !fs.IsDir("/app/new/dir") {
+		t.Errorf("TestFsOverlayWrites: expected directory to be created in overlay only")
+	}
+
+	err = fs.RemoveAll("/app/sub")
+	if err != nil {
+		t.Fatalf("TestFsOverlayWrites: %s", err)
+	}
+	if fs.Exists("/app/sub") {
+		t.Errorf("TestFsOverlayWrites: expected /app/sub to be removed from the union")
+	}
+	if !fs.base.Exists("/app/sub/c.yaml") {
+		t.Errorf("TestFsOverlayWrites: expected /app/sub to be kept in the base")
+	}
+
+	if err := fs.RemoveAll("/app/missing"); err == nil {
+		t.Errorf("TestFsOverlayWrites: expected error removing missing path")
+	}
+}
+
+func TestFsOverlayOpenCreate(t *testing.T) {
+	fs := makeTestFsOverlay()
+
+	cases := map[string]string{
+		"/app/a.yaml": "base a",
+		"/app/b.yaml": "overlay b",
+		"/app/d.yaml": "overlay d",
+	}
+	for name, want := range cases {
+		f, err := fs.Open(name)
+		if err != nil {
+			t.Errorf("TestFsOverlayOpenCreate: %s: %s", name, err)
+			continue
+		}
+		// in-memory files never return io.EOF, read them at once
+		buf := make([]byte, 64)
+		n, err := f.Read(buf)
+		f.Close()
+		if err != nil {
+			t.Errorf("TestFsOverlayOpenCreate: %s: %s", name, err)
+			continue
+		}
+		if got := buf[:n]; string(got) != want {
+			t.Errorf("TestFsOverlayOpenCreate: %s, got: %s, want: %s.", name, got, want)
+		}
+	}
+
+	if _, err := fs.Open("/app/missing.yaml"); err == nil {
+		t.Errorf("TestFsOverlayOpenCreate: expected error opening missing file")
+	}
+
+	// creating a base file shadows it with an empty overlay file
+	f, err := fs.Create("/app/a.yaml")
+	if err != nil {
+		t.Fatalf("TestFsOverlayOpenCreate: %s", err)
+	}
+	f.Close()
+
+	got, _ := fs.ReadFile("/app/a.yaml")
+	if string(got) != "" {
+		t.Errorf("TestFsOverlayOpenCreate: expected created file to be empty, got: %s.", got)
+	}
+	base, _ := fs.base.ReadFile("/app/a.yaml")
+	if string(base) != "base a" {
+		t.Errorf("TestFsOverlayOpenCreate: base modified, got: %s, want: %s.", base, "base a")
+	}
+
+	f, err = fs.Create("/app/created.yaml")
+	if err != nil {
+		t.Fatalf("TestFsOverlayOpenCreate: %s", err)
+	}
+	f.Close()
+	if !fs.Exists("/app/created.yaml") || fs.base.Exists("/app/created.yaml") {
+		t.Errorf("TestFsOverlayOpenCreate: expected file to be created in overlay only")
+	}
+}
+
+func TestFsOverlayMkdirCleanedAbs(t *testing.T) {
+	fs := makeTestFsOverlay()
+
+	err := fs.Mkdir("/app/new")
+	if err != nil {
+		t.Fatalf("TestFsOverlayMkdirCleanedAbs: %s", err)
+	}
+	if fs.base.Exists("/app/new") || !fs.IsDir("/app/new") {
+		t.Errorf("TestFsOverlayMkdirCleanedAbs: expected directory to be created in overlay only")
+	}
+
+	cases := []struct {
+		path string
+		dir  string
+		file string
+	}{
+		{"/app/a.yaml", "/app", "a.yaml"},
+		{"/app/d.yaml", "/app", "d.yaml"},
+		{"/app/sub", "/app/sub", ""},
+		{"/app/new", "/app/new", ""},
+		// a file in the base, but a directory in the overlay
+		{"/app/file-or-dir", "/app/file-or-dir", ""},
+	}
+	for _, tc := range cases {
+		dir, file, err := fs.CleanedAbs(tc.path)
+		if err != nil {
+			t.Errorf("TestFsOverlayMkdirCleanedAbs: %s: %s", tc.path, err)
+			continue
+		}
+		if string(dir) != tc.dir || file != tc.file {
+			t.Errorf("TestFsOverlayMkdirCleanedAbs: %s, got: %s %s, want: %s %s.", tc.path, dir, file, tc.dir, tc.file)
+		}
+	}
+
+	if _, _, err := fs.CleanedAbs("/app/missing.yaml"); err == nil {
+		t.Errorf("TestFsOverlayMkdirCleanedAbs: expected error for missing path")
+	}
+}
+
+func TestFsOverlayRemoveAll(t *testing.T) {
+	fs := makeTestFsOverlay()
+
+	for _, name := range []string{"/app/a.yaml", "/app/b.yaml", "/app/sub"} {
+		err := fs.RemoveAll(name)
+		if err != nil {
+			t.Fatalf("TestFsOverlayRemoveAll: %s: %s", name, err)
+		}
+	}
+
+	for _, name := range []string{"/app/a.yaml", "/app/b.yaml", "/app/sub", "/app/sub/c.yaml", "/app/sub/e.yaml"} {
+		if fs.Exists(name) || fs.IsDir(name) {
+			t.Errorf("TestFsOverlayRemoveAll: expected %s to be removed", name)
+		}
+		if _, err := fs.ReadFile(name); err == nil {
+			t.Errorf("TestFsOverlayRemoveAll: expected error reading removed %s", name)
+		}
+		if _, err := fs.Open(name); err == nil {
+			t.Errorf("TestFsOverlayRemoveAll: expected error opening removed %s", name)
+		}
+		if _, _, err := fs.CleanedAbs(name); err == nil {
+			t.Errorf("TestFsOverlayRemoveAll: expected error cleaning removed %s", name)
+		}
+	}
+
+	for _, name := range []string{"/app/a.yaml", "/app/b.yaml", "/app/sub/c.yaml"} {
+		if !fs.base.Exists(name) {
+			t.Errorf("TestFsOverlayRemoveAll: expected %s to be kept in the base", name)
+		}
+	}
+
+	matches, err := fs.Glob("/app/*.yaml")
+	if err != nil {
+		t.Fatalf("TestFsOverlayRemoveAll: %s", err)
+	}
+	if want := []string{"/app/d.yaml"}; !reflect.DeepEqual(matches, want) {
+		t.Errorf("TestFsOverlayRemoveAll: unexpected glob, got: %v, want: %v.", matches, want)
+	}
+
+	var walked []string
+	err = fs.Walk("/app", func(p string, info os.FileInfo, err error) error {
+		walked = append(walked, p)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("TestFsOverlayRemoveAll: %s", err)
+	}
+	want := []string{"/app", "/app/d.yaml", "/app/file-or-dir", "/app/file-or-dir/f.yaml"}
+	if !reflect.DeepEqual(walked, want) {
+		t.Errorf("TestFsOverlayRemoveAll: unexpected walk, got: %v, want: %v.", walked, want)
+	}
+
+	// the overlay can recreate removed paths, the base stays hidden
+	err = fs.WriteFile("/app/sub/g.yaml", []byte("overlay g"))
+	if err != nil {
+		t.Fatalf("TestFsOverlayRemoveAll: %s", err)
+	}
+	if !fs.Exists("/app/sub/g.yaml") || fs.Exists("/app/sub/c.yaml") {
+		t.Errorf("TestFsOverlayRemoveAll: expected only the recreated file below /app/sub")
+	}
+}
+
+func TestFsOverlayWalk(t *testing.T) {
+	fs := makeTestFsOverlay()
+
+	var got []string
+	err := fs.Walk("/app", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TestFsOverlayWalk: %s", err)
+	}
+
+	want := []string{
+		"/app",
+		"/app/a.yaml",
+		"/app/b.yaml",
+		"/app/d.yaml",
+		"/app/file-or-dir",
+		"/app/file-or-dir/f.yaml",
+		"/app/sub",
+		"/app/sub/c.yaml",
+		"/app/sub/e.yaml",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TestFsOverlayWalk: got: %s, want: %s.", got, want)
+	}
+}
+
+func TestFsOverlayWalkSkipDir(t *testing.T) {
+	fs := makeTestFsOverlay()
+
+	var got []string
+	err := fs.Walk("/app", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == "/app/sub" {
+			return filepath.SkipDir
+		}
+		got = append(got, path)
+		if path == "/app/b.yaml" {
+			// skips remaining files in /app
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TestFsOverlayWalkSkipDir: %s", err)
+	}
+
+	want := []string{"/app", "/app/a.yaml", "/app/b.yaml"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TestFsOverlayWalkSkipDir: got: %s, want: %s.", got, want)
+	}
+}
+
+// failingWalkFs passes err to walkFn for the path failPath, or
+// if failPath is empty, fails the whole walk with err
+type failingWalkFs struct {
+	filesys.FileSystem
+	failPath string
+	err      error
+}
+
+func (fs failingWalkFs) Walk(path string, walkFn filepath.WalkFunc) error {
+	if fs.failPath == "" {
+		return fs.err
+	}
+	return fs.FileSystem.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if p == fs.failPath {
+			err = fs.err
+		}
+		return walkFn(p, info, err)
+	})
+}
+
+func TestFsOverlayWalkErrors(t *testing.T) {
+	cases := []struct {
+		name      string
+		overlay   bool
+		failPath  string
+		wantErrAt string
+	}{
+		{"failing base sub-walk", false, "/app/sub", "/app/sub"},
+		{"failing overlay sub-walk", true, "/app/d.yaml", "/app/d.yaml"},
+		{"failing base walk", false, "", ""},
+		{"failing overlay walk", true, "", ""},
+	}
+
+	for _, tc := range cases {
+		fs := makeTestFsOverlay()
+		walkErr := errors.New(tc.name)
+		if tc.overlay {
+			fs.overlay = failingWalkFs{fs.overlay, tc.failPath, walkErr}
+		} else {
+			fs.base = failingWalkFs{fs.base, tc.failPath, walkErr}
+		}
+
+		errAt := ""
+		err := fs.Walk("/app", func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				errAt = path
+			}
+			return err
+		})
+		if err != walkErr {
+			t.Errorf("TestFsOverlayWalkErrors: %s: expected the walk error to be returned, got: %v", tc.name, err)
+		}
+		if errAt != tc.wantErrAt {
+			t.Errorf("TestFsOverlayWalkErrors: %s: expected walkFn to get the error for %s, got: %s.", tc.name, tc.wantErrAt, errAt)
+		}
+	}
+}
+
+func TestFsOverlayWalkMissing(t *testing.T) {
+	fs := makeTestFsOverlay()
+
+	called := false
+	err := fs.Walk("/missing", func(path string, info os.FileInfo, err error) error {
+		called = true
+		return err
+	})
+	if !called || err == nil {
+		t.Errorf("TestFsOverlayWalkMissing: expected walkFn to be called with an error, got: %v", err)
+	}
+}
+
+func TestExpandResourceDocuments(t *testing.T) {
+	cases := []struct {
+		name  string
+		data  string
+		names []string
+		err   string
+	}{
+		{
+			name:  "single",
+			data:  "kind: ConfigMap\nmetadata:\n  name: a\n",
+			names: []string{"a"},
+		},
+		{
+			name:  "stream",
+			data:  "---\nkind: ConfigMap\nmetadata:\n  name: a\n---\n---\nkind: ConfigMap\nmetadata:\n  name: b\n",
+			names: []string{"a", "b"},
+		},
+		{
+			name:  "kind list",
+			data:  `{"kind": "List", "items": [{"kind": "ConfigMap", "metadata": {"name": "a"}}, {"kind": "ConfigMapList", "items": [{"kind": "ConfigMap", "metadata": {"name": "b"}}]}]}`,
+			names: []string{"a", "b"},
+		},
+		{
+			name:  "json array",
+			data:  `[{"kind": "ConfigMap", "metadata": {"name": "a"}}, {"kind": "ConfigMap", "metadata": {"name": "b"}}]`,
+			names: []string{"a", "b"},
+		},
+		{
+			name: "invalid document",
+			data: "kind: ConfigMap\n---\nkind: [\n",
+			err:  "document 1: ",
+		},
+		{
+			name: "invalid item",
+			data: "kind: ConfigMap\n---\nkind: List\nitems:\n- kind: ConfigMap\n- not a resource\n",
+			err:  "document 1: item 1: expected a resource or a list of resources",
+		},
+	}
+
+	for _, c := range cases {
+		out, err := expandResourceDocuments([]byte(c.data))
+		if c.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), c.err) {
+				t.Errorf("TestExpandResourceDocuments: %s, got: %v, want: %s.", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("TestExpandResourceDocuments: %s: %s", c.name, err)
+			continue
+		}
+
+		var names []string
+		for _, doc := range strings.Split(string(out), "---\n") {
+			var r struct {
+				Metadata struct {
+					Name string `yaml:"name"`
+				} `yaml:"metadata"`
+			}
+			yaml.Unmarshal([]byte(doc), &r)
+			names = append(names, r.Metadata.Name)
+		}
+		if !reflect.DeepEqual(names, c.names) {
+			t.Errorf("TestExpandResourceDocuments: %s, got: %s, want: %s.", c.name, names, c.names)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package kustomize
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+	"sigs.k8s.io/kustomize/api/builtins"
+	"sigs.k8s.io/kustomize/api/konfig/builtinpluginconsts"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/types"
+)
+
+// defaultFieldSpecs returns kustomize's default
+// field specs of the given transformer config
+func defaultFieldSpecs(name string) ([]types.FieldSpec, error) {
+	specs := make(map[string][]types.FieldSpec)
+	err := yaml.Unmarshal([]byte(builtinpluginconsts.GetDefaultFieldSpecsAsMap()[name]), &specs)
+	if err != nil {
+		return nil, fmt.Errorf("parsing default %s field specs failed: %s", name, err)
+	}
+
+	return specs[name], nil
+}
+
+// makeOverrideTransformers returns kustomize's builtin image and
+// replica transformers, to apply images and replicas to a ResMap the
+// same way a kustomization's images and replicas fields do
+func makeOverrideTransformers(images []types.Image, replicas []types.Replica) (transformers []resmap.Transformer, err error) {
+	if len(images) > 0 {
+		imageSpecs, err := defaultFieldSpecs("images")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, image := range images {
+			transformers = append(transformers, &builtins.ImageTagTransformerPlugin{
+				ImageTag:   image,
+				FieldSpecs: imageSpecs,
+			})
+		}
+	}
+
+	if len(replicas) > 0 {
+		replicaSpecs, err := defaultFieldSpecs("replicas")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, replica := range replicas {
+			transformers = append(transformers, &builtins.ReplicaCountTransformerPlugin{
+				Replica:    replica,
+				FieldSpecs: replicaSpecs,
+			})
+		}
+	}
+
+	return transformers, nil
+}
+
+// applyTransformers applies the transformers to rm in order
+func applyTransformers(rm resmap.ResMap, transformers []resmap.Transformer) error {
+	for _, t := range transformers {
+		err := t.Transform(rm)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
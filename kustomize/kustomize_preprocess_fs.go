@@ -0,0 +1,112 @@
+package kustomize
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/konfig"
+)
+
+var _ filesys.FileSystem = fsPreprocessor{}
+
+// kustomizationPreprocessor rewrites a kustomization before it is
+// handed to krusty. It receives the directory the kustomization was
+// read from and can add generated files to the overlay.
+type kustomizationPreprocessor func(fs fsPreprocessor, dir string, kustomization map[interface{}]interface{}) error
+
+// fsPreprocessor wraps an FsOverlay and runs the preprocessors
+// every time krusty reads a kustomization file.
+type fsPreprocessor struct {
+	FsOverlay
+	preprocessors []kustomizationPreprocessor
+	// kustomize reports kustomizations that fail to read as
+	// missing, so the last preprocessing error is kept here
+	lastErr *error
+}
+
+// makeFsPreprocessor makes an instance of fsPreprocessor on top of
+// the given filesystem.
+func makeFsPreprocessor(base filesys.FileSystem, preprocessors ...kustomizationPreprocessor) fsPreprocessor {
+	return fsPreprocessor{
+		FsOverlay:     makeFsOverlayOn(base, ""),
+		preprocessors: preprocessors,
+		lastErr:       new(error),
+	}
+}
+
+// ReadFile returns kustomization files after running all
+// preprocessors and delegates for all other files.
+func (fs fsPreprocessor) ReadFile(name string) ([]byte, error) {
+	if !isKustomizationFile(name) {
+		return fs.FsOverlay.ReadFile(name)
+	}
+
+	data, err := fs.FsOverlay.ReadFile(name)
+	if err != nil {
+		// kustomize reports any error reading the
+		// kustomization as not finding it
+		if fs.Exists(name) {
+			*fs.lastErr = fmt.Errorf("reading '%s' failed: %s", name, err)
+		}
+		return data, err
+	}
+	if len(fs.preprocessors) == 0 {
+		return data, nil
+	}
+
+	kustomization, err := fromYaml(string(data))
+	if err != nil {
+		*fs.lastErr = err
+		return nil, err
+	}
+	if kustomization == nil {
+		return data, nil
+	}
+
+	dir := filepath.Dir(name)
+	for _, p := range fs.preprocessors {
+		err := p(fs, dir, kustomization)
+		if err != nil {
+			*fs.lastErr = fmt.Errorf("preprocessing '%s' failed: %s", name, err)
+			return nil, *fs.lastErr
+		}
+	}
+
+	// return unchanged kustomizations as is, to not
+	// alter them by round tripping through yaml
+	original, _ := fromYaml(string(data))
+	if reflect.DeepEqual(original, kustomization) {
+		return data, nil
+	}
+
+	return toYaml(kustomization)
+}
+
+// Err returns the last preprocessing error
+func (fs fsPreprocessor) Err() error {
+	return *fs.lastErr
+}
+
+func isKustomizationFile(name string) bool {
+	base := filepath.Base(name)
+	for _, kf := range konfig.RecognizedKustomizationFileNames() {
+		if base == kf {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appendKustomizationResource adds a file name to the resources
+// of a kustomization
+func appendKustomizationResource(kustomization map[interface{}]interface{}, name string) {
+	var resources []interface{}
+	if value, ok := kustomization["resources"].([]interface{}); ok {
+		resources = value
+	}
+
+	kustomization["resources"] = append(resources, name)
+}
@@ -0,0 +1,138 @@
+package kustomize
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+var _ filesys.FileSystem = fsSopsDecrypter{}
+
+// fsSopsDecrypter wraps a FileSystem and transparently decrypts
+// SOPS encrypted files when they are read. Decryption runs the sops
+// binary on a temporary copy of the encrypted data, decrypted data
+// is only ever held in memory.
+type fsSopsDecrypter struct {
+	filesys.FileSystem
+	sopsPath string
+	ageKey   string
+}
+
+// makeFsSopsDecrypter makes an instance of fsSopsDecrypter.
+func makeFsSopsDecrypter(base filesys.FileSystem, sopsPath string, ageKey string) fsSopsDecrypter {
+	return fsSopsDecrypter{
+		FileSystem: base,
+		sopsPath:   sopsPath,
+		ageKey:     ageKey,
+	}
+}
+
+// ReadFile decrypts SOPS encrypted files and delegates
+// for all other files.
+func (fs fsSopsDecrypter) ReadFile(name string) ([]byte, error) {
+	data, err := fs.FileSystem.ReadFile(name)
+	if err != nil {
+		return data, err
+	}
+
+	if !isSopsEncrypted(name, data) {
+		return data, nil
+	}
+
+	return fs.decrypt(name, data)
+}
+
+// decrypt writes the encrypted data to a temporary file with the
+// extension of name, because files may only exist in memory, and
+// lets sops detect the format from the extension, like it does
+// for the original file
+func (fs fsSopsDecrypter) decrypt(name string, data []byte) ([]byte, error) {
+	f, err := ioutil.TempFile("", "kustomize-sops-*"+filepath.Ext(name))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting '%s' failed: %s", name, err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decrypting '%s' failed: %s", name, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(fs.sopsPath, "--decrypt", f.Name())
+	cmd.Env = os.Environ()
+	if fs.ageKey != "" {
+		cmd.Env = append(cmd.Env, "SOPS_AGE_KEY="+fs.ageKey)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("decrypting '%s' using '%s' failed: %s: %s", name, fs.sopsPath, err, stderr.String())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// isSopsEncrypted detects SOPS encrypted data, in the
+// format sops expects for the extension of name
+func isSopsEncrypted(name string, data []byte) bool {
+	if !bytes.Contains(data, []byte("sops")) {
+		return false
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".env":
+		return isSopsDotenv(data)
+	case ".json", ".yaml", ".yml":
+		return isSopsDocument(data)
+	}
+
+	// sops stores other files, e.g. secretGenerator
+	// files, as a JSON document with a data key
+	if !isSopsDocument(data) {
+		return false
+	}
+	var doc map[string]interface{}
+	if yaml.Unmarshal(data, &doc) != nil {
+		return false
+	}
+	_, ok := doc["data"]
+	return ok
+}
+
+func isSopsDocument(data []byte) bool {
+	var doc struct {
+		Sops map[string]interface{} `yaml:"sops"`
+	}
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return false
+	}
+
+	_, ok := doc.Sops["mac"]
+	return ok
+}
+
+func isSopsDotenv(data []byte) bool {
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		if strings.HasPrefix(s.Text(), "sops_mac=") {
+			return true
+		}
+	}
+
+	return false
+}
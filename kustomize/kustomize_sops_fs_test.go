@@ -0,0 +1,109 @@
+package kustomize
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/resid"
+)
+
+// fakeSops prints a dotenv file with the age key it was called with,
+// if it was called with the path to a dotenv file with encrypted data
+const fakeSops = `#!/bin/sh
+if [ "$#" != 2 ] || [ "$1" != "--decrypt" ]; then
+  echo "unexpected arguments $*" >&2
+  exit 1
+fi
+case "$2" in
+  *.env) ;;
+  *) echo "unexpected extension $2" >&2; exit 1 ;;
+esac
+if ! grep -q sops_mac "$2"; then
+  echo "missing encrypted data in $2" >&2
+  exit 1
+fi
+echo "password=$SOPS_AGE_KEY"
+`
+
+func TestIsSopsEncrypted(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"secret.enc.yaml", "password: ENC[AES256_GCM,data:abc]\nsops:\n  mac: ENC[AES256_GCM,data:def]\n", true},
+		{"secret.enc.json", "{\"password\": \"ENC[AES256_GCM,data:abc]\", \"sops\": {\"mac\": \"ENC[AES256_GCM,data:def]\"}}", true},
+		{"secret.env", "password=ENC[AES256_GCM,data:abc]\nsops_mac=ENC[AES256_GCM,data:def]\n", true},
+		{"tls.key", "{\"data\": \"ENC[AES256_GCM,data:abc]\", \"sops\": {\"mac\": \"ENC[AES256_GCM,data:def]\"}}", true},
+		{"configmap.yaml", "apiVersion: v1\nkind: ConfigMap\ndata:\n  sops: not encrypted\n", false},
+		{"plain.env", "sops=not encrypted\n", false},
+		// sops would decrypt these as binary files, based on the extension
+		{"secret.txt", "password=ENC[AES256_GCM,data:abc]\nsops_mac=ENC[AES256_GCM,data:def]\n", false},
+	}
+
+	for _, c := range cases {
+		got := isSopsEncrypted(c.name, []byte(c.data))
+		if got != c.want {
+			t.Errorf("TestIsSopsEncrypted: %s: got: %t, want: %t.", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSopsDecrypterSecretGenerator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kustomize-sops")
+	if err != nil {
+		t.Fatalf("TestSopsDecrypterSecretGenerator: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sopsPath := filepath.Join(dir, "sops")
+	err = ioutil.WriteFile(sopsPath, []byte(fakeSops), 0755)
+	if err != nil {
+		t.Fatalf("TestSopsDecrypterSecretGenerator: %s", err)
+	}
+
+	fSys := filesys.MakeFsInMemory()
+	fSys.WriteFile("/app/kustomization.yaml", []byte(`
+secretGenerator:
+- name: test
+  envs:
+  - secret.env
+generatorOptions:
+  disableNameSuffixHash: true
+`))
+	fSys.WriteFile("/app/secret.env", []byte("password=ENC[AES256_GCM,data:abc]\nsops_mac=ENC[AES256_GCM,data:def]\n"))
+
+	tmpDir := filepath.Join(dir, "tmp")
+	err = os.Mkdir(tmpDir, 0755)
+	if err != nil {
+		t.Fatalf("TestSopsDecrypterSecretGenerator: %s", err)
+	}
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", tmpDir)
+
+	config := &Config{SopsPath: sopsPath, SopsAgeKey: "decrypted"}
+	rm, err := runKustomizeBuildWithFileSys(makeBuildFileSys(fSys, config, nil), "/app")
+	if err != nil {
+		t.Fatalf("TestSopsDecrypterSecretGenerator: %s", err)
+	}
+
+	id := resid.NewResId(resid.Gvk{Version: "v1", Kind: "Secret"}, "test")
+	r, err := rm.GetByCurrentId(id)
+	if err != nil {
+		t.Fatalf("TestSopsDecrypterSecretGenerator: %s", err)
+	}
+
+	data := r.Map()["data"].(map[string]interface{})
+	if data["password"] != "ZGVjcnlwdGVk" {
+		t.Errorf("TestSopsDecrypterSecretGenerator: incorrect password, got: %s, want: %s.", data["password"], "ZGVjcnlwdGVk")
+	}
+
+	// other temporary files are left by kustomize
+	left, _ := filepath.Glob(filepath.Join(tmpDir, "kustomize-sops-*"))
+	if len(left) != 0 {
+		t.Errorf("TestSopsDecrypterSecretGenerator: expected temporary files to be removed, got: %v.", left)
+	}
+}
@@ -0,0 +1,159 @@
+package kustomize
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+var _ filesys.FileSystem = fsVariableSubstituter{}
+
+// variableFilesDefault are the globs of the files
+// variables are substituted in, if not configured
+var variableFilesDefault = []string{"*.yaml", "*.yml"}
+
+// variableReference matches ${VAR} and the escaped form $${VAR}
+var variableReference = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// variableSubstitution configures fsVariableSubstituter
+type variableSubstitution struct {
+	variables map[string]string
+	strict    bool
+	files     []string
+}
+
+func variableSubstitutionSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"variables": {
+			Type:        schema.TypeMap,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Variables to substitute for ${VAR} references in files before the build. Use $${VAR} for a literal ${VAR}.",
+		},
+		"variables_strict": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Fail on references to undefined variables, instead of leaving them unchanged.",
+		},
+		"variables_files": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "Globs of the files variables are substituted in, matched against the trailing path elements. Defaults to *.yaml and *.yml.",
+		},
+	}
+}
+
+// withVariableSubstitutionSchema adds the variable
+// substitution attributes to a data source's schema
+func withVariableSubstitutionSchema(s map[string]*schema.Schema) map[string]*schema.Schema {
+	for k, v := range variableSubstitutionSchema() {
+		s[k] = v
+	}
+
+	return s
+}
+
+// expandVariableSubstitution returns nil if substitution is disabled
+func expandVariableSubstitution(d *schema.ResourceData) *variableSubstitution {
+	vs := &variableSubstitution{
+		variables: expandStringMap(d.Get("variables").(map[string]interface{})),
+		strict:    d.Get("variables_strict").(bool),
+		files:     expandStringList(d.Get("variables_files").([]interface{})),
+	}
+
+	if len(vs.variables) == 0 && !vs.strict {
+		return nil
+	}
+
+	return vs
+}
+
+// fsVariableSubstituter wraps a FileSystem and substitutes
+// variables in the files matching the configured globs
+// when they are read.
+type fsVariableSubstituter struct {
+	filesys.FileSystem
+	variableSubstitution
+}
+
+// makeFsVariableSubstituter makes an instance of fsVariableSubstituter.
+func makeFsVariableSubstituter(base filesys.FileSystem, vs variableSubstitution) fsVariableSubstituter {
+	if len(vs.files) == 0 {
+		vs.files = variableFilesDefault
+	}
+
+	return fsVariableSubstituter{
+		FileSystem:           base,
+		variableSubstitution: vs,
+	}
+}
+
+// ReadFile substitutes variables in matching
+// files and delegates for all other files.
+func (fs fsVariableSubstituter) ReadFile(name string) ([]byte, error) {
+	data, err := fs.FileSystem.ReadFile(name)
+	if err != nil {
+		return data, err
+	}
+
+	if !matchesFileGlobs(name, fs.files) {
+		return data, nil
+	}
+
+	return substituteVariables(name, data, fs.variables, fs.strict)
+}
+
+// matchesFileGlobs matches each glob against as many
+// trailing path elements as the glob has
+func matchesFileGlobs(name string, globs []string) bool {
+	elements := strings.Split(filepath.ToSlash(filepath.Clean(name)), "/")
+
+	for _, glob := range globs {
+		n := len(strings.Split(filepath.ToSlash(glob), "/"))
+		if n > len(elements) {
+			continue
+		}
+
+		trailing := strings.Join(elements[len(elements)-n:], "/")
+		if ok, _ := filepath.Match(filepath.ToSlash(glob), trailing); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// substituteVariables replaces ${VAR} references with their value and
+// unescapes $${VAR}. Undefined variables are left unchanged, or are
+// an error if strict is set.
+func substituteVariables(name string, data []byte, variables map[string]string, strict bool) ([]byte, error) {
+	var undefined []string
+
+	out := variableReference.ReplaceAllFunc(data, func(ref []byte) []byte {
+		if bytes.HasPrefix(ref, []byte("$$")) {
+			return ref[1:]
+		}
+
+		v := string(variableReference.FindSubmatch(ref)[1])
+		value, ok := variables[v]
+		if !ok {
+			undefined = append(undefined, v)
+			return ref
+		}
+
+		return []byte(value)
+	})
+
+	if strict && len(undefined) > 0 {
+		return nil, fmt.Errorf("file '%s' references undefined variables: %s", name, strings.Join(undefined, ", "))
+	}
+
+	return out, nil
+}
@@ -0,0 +1,139 @@
+package kustomize
+
+import (
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/resid"
+)
+
+func TestSubstituteVariables(t *testing.T) {
+	variables := map[string]string{
+		"CLUSTER": "prod",
+		"DOMAIN":  "example.com",
+	}
+
+	cases := []struct {
+		name   string
+		data   string
+		strict bool
+		want   string
+		err    string
+	}{
+		{
+			name: "defined",
+			data: "host: ${CLUSTER}.${DOMAIN}",
+			want: "host: prod.example.com",
+		},
+		{
+			name:   "escaped",
+			data:   "script: echo $${HOME} ${CLUSTER}",
+			strict: true,
+			want:   "script: echo ${HOME} prod",
+		},
+		{
+			name: "undefined",
+			data: "image: nginx:${TAG} $HOME",
+			want: "image: nginx:${TAG} $HOME",
+		},
+		{
+			name:   "undefined strict",
+			data:   "image: ${REGISTRY}/nginx:${TAG}",
+			strict: true,
+			err:    "file 'test.yaml' references undefined variables: REGISTRY, TAG",
+		},
+	}
+
+	for _, c := range cases {
+		got, err := substituteVariables("test.yaml", []byte(c.data), variables, c.strict)
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("TestSubstituteVariables: %s, got: %v, want: %s.", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("TestSubstituteVariables: %s: %s", c.name, err)
+			continue
+		}
+		if string(got) != c.want {
+			t.Errorf("TestSubstituteVariables: %s, got: %s, want: %s.", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMatchesFileGlobs(t *testing.T) {
+	cases := []struct {
+		name  string
+		globs []string
+		want  bool
+	}{
+		{"/app/deployment.yaml", variableFilesDefault, true},
+		{"/app/service.yml", variableFilesDefault, true},
+		{"/app/script.sh", variableFilesDefault, false},
+		{"/app/overlays/prod/kustomization.yaml", []string{"prod/*.yaml"}, true},
+		{"/app/overlays/dev/kustomization.yaml", []string{"prod/*.yaml"}, false},
+		{"kustomization.yaml", []string{"prod/*.yaml"}, false},
+	}
+
+	for _, c := range cases {
+		if got := matchesFileGlobs(c.name, c.globs); got != c.want {
+			t.Errorf("TestMatchesFileGlobs: %s %s, got: %t, want: %t.", c.name, c.globs, got, c.want)
+		}
+	}
+}
+
+func TestVariableSubstituterBuild(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	fSys.WriteFile("/app/kustomization.yaml", []byte(`
+namespace: ${NAMESPACE}
+resources:
+- configmap.yaml
+`))
+	fSys.WriteFile("/app/configmap.yaml", []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+data:
+  host: ${CLUSTER}.example.com
+`))
+
+	vs := &variableSubstitution{
+		variables: map[string]string{"NAMESPACE": "test-vars", "CLUSTER": "prod"},
+	}
+	rm, err := runKustomizeBuildWithFileSys(makeBuildFileSys(fSys, &Config{}, vs), "/app")
+	if err != nil {
+		t.Fatalf("TestVariableSubstituterBuild: %s", err)
+	}
+
+	id := resid.NewResIdWithNamespace(resid.Gvk{Version: "v1", Kind: "ConfigMap"}, "test", "test-vars")
+	r, err := rm.GetByCurrentId(id)
+	if err != nil {
+		t.Fatalf("TestVariableSubstituterBuild: %s", err)
+	}
+
+	data := r.Map()["data"].(map[string]interface{})
+	if data["host"] != "prod.example.com" {
+		t.Errorf("TestVariableSubstituterBuild: got: %s, want: %s.", data["host"], "prod.example.com")
+	}
+
+	vs.strict = true
+	delete(vs.variables, "CLUSTER")
+	_, err = runKustomizeBuildWithFileSys(makeBuildFileSys(fSys, &Config{}, vs), "/app")
+	if err == nil || !strings.Contains(err.Error(), "undefined variables: CLUSTER") {
+		t.Errorf("TestVariableSubstituterBuild: expected undefined variable error, got: %v", err)
+	}
+}
+
+func TestVariableSubstituterStrictKustomization(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	fSys.WriteFile("/app/kustomization.yaml", []byte("namespace: ${NAMESPACE}\n"))
+
+	vs := &variableSubstitution{strict: true}
+	_, err := runKustomizeBuildWithFileSys(makeBuildFileSys(fSys, &Config{}, vs), "/app")
+	if err == nil || !strings.Contains(err.Error(), "undefined variables: NAMESPACE") {
+		t.Errorf("TestVariableSubstituterStrictKustomization: expected undefined variable error, got: %v", err)
+	}
+}
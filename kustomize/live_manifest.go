@@ -0,0 +1,200 @@
+package kustomize
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// defaultedField is a field the API server sets, that is removed
+// from live objects if it has value, or any value if value is nil.
+// A "*" in path matches every item of a list.
+type defaultedField struct {
+	path  []string
+	value interface{}
+}
+
+// anyValueExcept as a defaultedField's value matches every
+// value, except the one users set themselves
+type anyValueExcept struct {
+	value interface{}
+}
+
+// matches returns if v is the field's defaulted value
+func (f defaultedField) matches(v interface{}) bool {
+	if e, ok := f.value.(anyValueExcept); ok {
+		return !reflect.DeepEqual(v, e.value)
+	}
+
+	return f.value == nil || reflect.DeepEqual(v, f.value)
+}
+
+// serverSetFields are removed from all live objects
+var serverSetFields = []defaultedField{
+	{[]string{"status"}, nil},
+	{[]string{"metadata", "uid"}, nil},
+	{[]string{"metadata", "resourceVersion"}, nil},
+	{[]string{"metadata", "creationTimestamp"}, nil},
+	{[]string{"metadata", "selfLink"}, nil},
+	{[]string{"metadata", "generation"}, nil},
+	{[]string{"metadata", "managedFields"}, nil},
+	{[]string{"metadata", "deletionTimestamp"}, nil},
+	{[]string{"metadata", "deletionGracePeriodSeconds"}, nil},
+}
+
+// podSpecDefaults are the defaults of pod specs,
+// relative to the pod template of workloads
+var podSpecDefaults = []defaultedField{
+	{[]string{"metadata", "creationTimestamp"}, nil},
+	{[]string{"spec", "restartPolicy"}, "Always"},
+	{[]string{"spec", "terminationGracePeriodSeconds"}, int64(30)},
+	{[]string{"spec", "dnsPolicy"}, "ClusterFirst"},
+	{[]string{"spec", "schedulerName"}, "default-scheduler"},
+	{[]string{"spec", "securityContext"}, map[string]interface{}{}},
+	{[]string{"spec", "containers", "*", "terminationMessagePath"}, "/dev/termination-log"},
+	{[]string{"spec", "containers", "*", "terminationMessagePolicy"}, "File"},
+	{[]string{"spec", "containers", "*", "resources"}, map[string]interface{}{}},
+	{[]string{"spec", "containers", "*", "ports", "*", "protocol"}, "TCP"},
+	{[]string{"spec", "initContainers", "*", "terminationMessagePath"}, "/dev/termination-log"},
+	{[]string{"spec", "initContainers", "*", "terminationMessagePolicy"}, "File"},
+	{[]string{"spec", "initContainers", "*", "resources"}, map[string]interface{}{}},
+}
+
+// kindDefaultedFields are the known defaulted fields per GroupKind
+var kindDefaultedFields = map[k8sschema.GroupKind][]defaultedField{
+	{Group: "", Kind: "Namespace"}: {
+		{[]string{"metadata", "labels", "kubernetes.io/metadata.name"}, nil},
+		{[]string{"spec", "finalizers"}, []interface{}{"kubernetes"}},
+	},
+	{Group: "", Kind: "Service"}: {
+		// allocated IPs, headless Services set "None" themselves
+		{[]string{"spec", "clusterIP"}, anyValueExcept{"None"}},
+		{[]string{"spec", "clusterIPs"}, anyValueExcept{[]interface{}{"None"}}},
+		{[]string{"spec", "ipFamilies"}, nil},
+		{[]string{"spec", "ipFamilyPolicy"}, "SingleStack"},
+		{[]string{"spec", "internalTrafficPolicy"}, "Cluster"},
+		{[]string{"spec", "sessionAffinity"}, "None"},
+		{[]string{"spec", "type"}, "ClusterIP"},
+		{[]string{"spec", "ports", "*", "protocol"}, "TCP"},
+	},
+	{Group: "", Kind: "ServiceAccount"}: {
+		{[]string{"secrets"}, nil},
+	},
+	{Group: "apps", Kind: "Deployment"}: append([]defaultedField{
+		{[]string{"metadata", "annotations", "deployment.kubernetes.io/revision"}, nil},
+		{[]string{"spec", "progressDeadlineSeconds"}, int64(600)},
+		{[]string{"spec", "revisionHistoryLimit"}, int64(10)},
+		{[]string{"spec", "strategy"}, map[string]interface{}{
+			"type": "RollingUpdate",
+			"rollingUpdate": map[string]interface{}{
+				"maxSurge":       "25%",
+				"maxUnavailable": "25%",
+			},
+		}},
+	}, prefixDefaultedFields([]string{"spec", "template"}, podSpecDefaults)...),
+	{Group: "apps", Kind: "StatefulSet"}: append([]defaultedField{
+		{[]string{"spec", "podManagementPolicy"}, "OrderedReady"},
+		{[]string{"spec", "revisionHistoryLimit"}, int64(10)},
+		{[]string{"spec", "updateStrategy"}, map[string]interface{}{
+			"type": "RollingUpdate",
+			"rollingUpdate": map[string]interface{}{
+				"partition": int64(0),
+			},
+		}},
+	}, prefixDefaultedFields([]string{"spec", "template"}, podSpecDefaults)...),
+	{Group: "apps", Kind: "DaemonSet"}: append([]defaultedField{
+		{[]string{"metadata", "annotations", "deprecated.daemonset.template.generation"}, nil},
+		{[]string{"spec", "revisionHistoryLimit"}, int64(10)},
+		{[]string{"spec", "updateStrategy"}, map[string]interface{}{
+			"type": "RollingUpdate",
+			"rollingUpdate": map[string]interface{}{
+				"maxSurge":       int64(0),
+				"maxUnavailable": int64(1),
+			},
+		}},
+	}, prefixDefaultedFields([]string{"spec", "template"}, podSpecDefaults)...),
+	{Group: "batch", Kind: "Job"}: append([]defaultedField{
+		{[]string{"spec", "selector"}, nil},
+		{[]string{"spec", "template", "metadata", "labels", "controller-uid"}, nil},
+		{[]string{"spec", "template", "metadata", "labels", "job-name"}, nil},
+		{[]string{"spec", "template", "metadata", "labels", "batch.kubernetes.io/controller-uid"}, nil},
+		{[]string{"spec", "template", "metadata", "labels", "batch.kubernetes.io/job-name"}, nil},
+		{[]string{"metadata", "labels", "controller-uid"}, nil},
+		{[]string{"metadata", "labels", "job-name"}, nil},
+		{[]string{"metadata", "labels", "batch.kubernetes.io/controller-uid"}, nil},
+		{[]string{"metadata", "labels", "batch.kubernetes.io/job-name"}, nil},
+		{[]string{"spec", "backoffLimit"}, int64(6)},
+		{[]string{"spec", "completionMode"}, "NonIndexed"},
+		{[]string{"spec", "completions"}, int64(1)},
+		{[]string{"spec", "parallelism"}, int64(1)},
+		{[]string{"spec", "suspend"}, false},
+	}, prefixDefaultedFields([]string{"spec", "template"}, podSpecDefaults)...),
+}
+
+func prefixDefaultedFields(prefix []string, fields []defaultedField) []defaultedField {
+	prefixed := make([]defaultedField, len(fields))
+	for i, f := range fields {
+		path := append(append([]string{}, prefix...), f.path...)
+		prefixed[i] = defaultedField{path, f.value}
+	}
+
+	return prefixed
+}
+
+// manifestFromLiveObject returns a manifest for objects without the
+// last applied configuration annotation, e.g. created by Helm, an
+// operator or kubectl create. It removes status, server set metadata
+// and known defaulted fields, so that importing and planning the
+// object's original manifest converges.
+func manifestFromLiveObject(u *k8sunstructured.Unstructured) (string, error) {
+	obj := u.DeepCopy().Object
+
+	gk := u.GroupVersionKind().GroupKind()
+	fields := append(append([]defaultedField{}, serverSetFields...), kindDefaultedFields[gk]...)
+	for _, f := range fields {
+		removeDefaultedField(obj, f.path, f)
+	}
+
+	manifest, err := json.Marshal(obj)
+	if err != nil {
+		return "", fmt.Errorf("marshaling live object failed: %s", err)
+	}
+
+	return string(manifest), nil
+}
+
+// removeDefaultedField removes the field at path from obj, if it
+// matches f. Maps left empty are removed too.
+func removeDefaultedField(obj map[string]interface{}, path []string, f defaultedField) {
+	v, ok := obj[path[0]]
+	if !ok {
+		return
+	}
+
+	if len(path) == 1 {
+		if f.matches(v) {
+			delete(obj, path[0])
+		}
+		return
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		removeDefaultedField(t, path[1:], f)
+		if len(t) == 0 {
+			delete(obj, path[0])
+		}
+	case []interface{}:
+		if path[1] != "*" || len(path) < 3 {
+			return
+		}
+		for _, item := range t {
+			if m, ok := item.(map[string]interface{}); ok {
+				removeDefaultedField(m, path[2:], f)
+			}
+		}
+	}
+}
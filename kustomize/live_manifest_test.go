@@ -0,0 +1,199 @@
+package kustomize
+
+import (
+	"encoding/json"
+	"testing"
+
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestManifestFromLiveObject(t *testing.T) {
+	cases := []struct {
+		name string
+		live string
+		want string
+	}{
+		{
+			"deployment",
+			`{
+				"apiVersion": "apps/v1",
+				"kind": "Deployment",
+				"metadata": {
+					"name": "test",
+					"namespace": "test-live",
+					"uid": "4bd5b2bd-8c3c-4d1b-9a4e-1b0d2c1f7e3a",
+					"resourceVersion": "42",
+					"generation": 1,
+					"creationTimestamp": "2020-01-01T00:00:00Z",
+					"selfLink": "/apis/apps/v1/namespaces/test-live/deployments/test",
+					"managedFields": [{"manager": "kubectl-create", "operation": "Update"}],
+					"annotations": {"deployment.kubernetes.io/revision": "1"},
+					"labels": {"app": "test"}
+				},
+				"spec": {
+					"progressDeadlineSeconds": 600,
+					"replicas": 1,
+					"revisionHistoryLimit": 3,
+					"selector": {"matchLabels": {"app": "test"}},
+					"strategy": {"type": "RollingUpdate", "rollingUpdate": {"maxSurge": "25%", "maxUnavailable": "25%"}},
+					"template": {
+						"metadata": {"creationTimestamp": null, "labels": {"app": "test"}},
+						"spec": {
+							"containers": [{
+								"name": "test",
+								"image": "nginx:1.19",
+								"imagePullPolicy": "IfNotPresent",
+								"ports": [{"containerPort": 80, "protocol": "TCP"}, {"containerPort": 53, "protocol": "UDP"}],
+								"resources": {},
+								"terminationMessagePath": "/dev/termination-log",
+								"terminationMessagePolicy": "File"
+							}],
+							"dnsPolicy": "ClusterFirst",
+							"restartPolicy": "Always",
+							"schedulerName": "default-scheduler",
+							"securityContext": {},
+							"terminationGracePeriodSeconds": 30
+						}
+					}
+				},
+				"status": {"replicas": 1, "readyReplicas": 1}
+			}`,
+			`{
+				"apiVersion": "apps/v1",
+				"kind": "Deployment",
+				"metadata": {"name": "test", "namespace": "test-live", "labels": {"app": "test"}},
+				"spec": {
+					"replicas": 1,
+					"revisionHistoryLimit": 3,
+					"selector": {"matchLabels": {"app": "test"}},
+					"template": {
+						"metadata": {"labels": {"app": "test"}},
+						"spec": {
+							"containers": [{
+								"name": "test",
+								"image": "nginx:1.19",
+								"imagePullPolicy": "IfNotPresent",
+								"ports": [{"containerPort": 80}, {"containerPort": 53, "protocol": "UDP"}]
+							}]
+						}
+					}
+				}
+			}`,
+		},
+		{
+			"service",
+			`{
+				"apiVersion": "v1",
+				"kind": "Service",
+				"metadata": {"name": "test", "namespace": "test-live", "uid": "4bd5b2bd-8c3c-4d1b-9a4e-1b0d2c1f7e3a"},
+				"spec": {
+					"clusterIP": "10.0.0.10",
+					"clusterIPs": ["10.0.0.10"],
+					"ipFamilies": ["IPv4"],
+					"ipFamilyPolicy": "SingleStack",
+					"ports": [{"port": 80, "protocol": "TCP", "targetPort": 8080}],
+					"selector": {"app": "test"},
+					"sessionAffinity": "None",
+					"type": "ClusterIP"
+				},
+				"status": {"loadBalancer": {}}
+			}`,
+			`{
+				"apiVersion": "v1",
+				"kind": "Service",
+				"metadata": {"name": "test", "namespace": "test-live"},
+				"spec": {
+					"ports": [{"port": 80, "targetPort": 8080}],
+					"selector": {"app": "test"}
+				}
+			}`,
+		},
+		{
+			"headless service",
+			`{
+				"apiVersion": "v1",
+				"kind": "Service",
+				"metadata": {"name": "test", "namespace": "test-live"},
+				"spec": {
+					"clusterIP": "None",
+					"clusterIPs": ["None"],
+					"ports": [{"port": 80, "protocol": "TCP", "targetPort": 8080}],
+					"selector": {"app": "test"},
+					"type": "ClusterIP"
+				}
+			}`,
+			`{
+				"apiVersion": "v1",
+				"kind": "Service",
+				"metadata": {"name": "test", "namespace": "test-live"},
+				"spec": {
+					"clusterIP": "None",
+					"clusterIPs": ["None"],
+					"ports": [{"port": 80, "targetPort": 8080}],
+					"selector": {"app": "test"}
+				}
+			}`,
+		},
+		{
+			"namespace",
+			`{
+				"apiVersion": "v1",
+				"kind": "Namespace",
+				"metadata": {"name": "test-live", "labels": {"kubernetes.io/metadata.name": "test-live"}},
+				"spec": {"finalizers": ["kubernetes"]},
+				"status": {"phase": "Active"}
+			}`,
+			`{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "test-live"}}`,
+		},
+		{
+			"configmap",
+			`{
+				"apiVersion": "v1",
+				"kind": "ConfigMap",
+				"metadata": {"name": "test", "namespace": "test-live", "resourceVersion": "1"},
+				"data": {"status": "kept"}
+			}`,
+			`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test", "namespace": "test-live"}, "data": {"status": "kept"}}`,
+		},
+	}
+
+	for _, tc := range cases {
+		u := &k8sunstructured.Unstructured{}
+		err := u.UnmarshalJSON([]byte(tc.live))
+		if err != nil {
+			t.Fatalf("TestManifestFromLiveObject: %s: %s", tc.name, err)
+		}
+
+		got, err := manifestFromLiveObject(u)
+		if err != nil {
+			t.Fatalf("TestManifestFromLiveObject: %s: %s", tc.name, err)
+		}
+
+		var want interface{}
+		err = json.Unmarshal([]byte(tc.want), &want)
+		if err != nil {
+			t.Fatalf("TestManifestFromLiveObject: %s: %s", tc.name, err)
+		}
+		wantJSON, _ := json.Marshal(want)
+
+		if got != string(wantJSON) {
+			t.Errorf("TestManifestFromLiveObject: %s: got: %s, want: %s.", tc.name, got, wantJSON)
+		}
+	}
+}
+
+func TestManifestFromLiveObjectKeepsInput(t *testing.T) {
+	u, err := parseJSON(`{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "test-live", "uid": "1"}}`)
+	if err != nil {
+		t.Fatalf("TestManifestFromLiveObjectKeepsInput: %s", err)
+	}
+
+	_, err = manifestFromLiveObject(u)
+	if err != nil {
+		t.Fatalf("TestManifestFromLiveObjectKeepsInput: %s", err)
+	}
+
+	if u.GetUID() != "1" {
+		t.Errorf("TestManifestFromLiveObjectKeepsInput: expected the live object to be unchanged, got: %v.", u.Object)
+	}
+}
@@ -0,0 +1,118 @@
+package kustomize
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// resourceLogger writes debug logs through the SDK's logger, as
+// key=value pairs tagged with the operation and the resid, to make
+// TF_LOG=DEBUG output of concurrent operations filterable
+type resourceLogger struct {
+	operation string
+	resid     string
+	// gk is used to redact errors of sensitive kinds
+	gk k8sschema.GroupKind
+}
+
+func newResourceLogger(operation string, u *k8sunstructured.Unstructured) resourceLogger {
+	return resourceLogger{
+		operation: operation,
+		resid:     residFor(u).String(),
+		gk:        u.GroupVersionKind().GroupKind(),
+	}
+}
+
+// debug logs event with the key value pairs in kv
+func (l resourceLogger) debug(event string, kv ...interface{}) {
+	l.print("DEBUG", event, kv)
+}
+
+// warn logs event at the WARN level, for changes that
+// are accepted but users should know about
+func (l resourceLogger) warn(event string, kv ...interface{}) {
+	l.print("WARN", event, kv)
+}
+
+func (l resourceLogger) print(level string, event string, kv []interface{}) {
+	fields := append([]interface{}{
+		"operation", l.operation,
+		"resid", l.resid,
+		"event", event,
+	}, kv...)
+
+	log.Printf("[%s] kustomization_resource: %s", level, formatLogFields(fields))
+}
+
+// apiCall logs a dynamic client request, with its
+// duration and the reason of the error, if any
+func (l resourceLogger) apiCall(verb string, gvr k8sschema.GroupVersionResource, namespace string, name string, start time.Time, err error) {
+	l.debug("api_call", append([]interface{}{
+		"verb", verb,
+		"resource", gvr.String(),
+		"namespace", namespace,
+		"name", name,
+		"duration", time.Since(start).String(),
+	}, errorLogFields(l.gk, err)...)...)
+}
+
+// patch logs a computed patch, Secret values
+// in patches of Secrets are redacted
+func (l resourceLogger) patch(gk k8sschema.GroupKind, patch []byte, dryRun bool) {
+	l.debug("patch",
+		"dry_run", dryRun,
+		"body", redactPatch(gk, patch))
+}
+
+// wait logs an iteration of a StateChangeConf's refresh function
+func (l resourceLogger) wait(waitingFor string, state string, err error) {
+	l.debug("wait", append([]interface{}{
+		"waiting_for", waitingFor,
+		"state", state,
+	}, errorLogFields(l.gk, err)...)...)
+}
+
+// logDiscoveryRefresh logs a refresh of the cached API group
+// resources, that is shared by all operations and resources
+func logDiscoveryRefresh(groups int, start time.Time, err error) {
+	fields := append([]interface{}{
+		"event", "discovery_refresh",
+		"groups", groups,
+		"duration", time.Since(start).String(),
+	}, errorLogFields(k8sschema.GroupKind{}, err)...)
+
+	log.Printf("[DEBUG] kustomization_resource: %s", formatLogFields(fields))
+}
+
+// errorLogFields returns the reason and the message of err,
+// redacted for sensitive kinds gk
+func errorLogFields(gk k8sschema.GroupKind, err error) []interface{} {
+	if err == nil {
+		return nil
+	}
+
+	return []interface{}{
+		"reason", string(k8serrors.ReasonForError(err)),
+		"error", redactError(gk, err),
+	}
+}
+
+// formatLogFields formats key value pairs as key=value, quoting strings
+func formatLogFields(kv []interface{}) string {
+	var pairs []string
+	for i := 0; i+1 < len(kv); i += 2 {
+		v := kv[i+1]
+		if s, ok := v.(string); ok {
+			v = fmt.Sprintf("%q", s)
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%v", kv[i], v))
+	}
+
+	return strings.Join(pairs, " ")
+}
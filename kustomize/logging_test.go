@@ -0,0 +1,150 @@
+package kustomize
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestFormatLogFields(t *testing.T) {
+	got := formatLogFields([]interface{}{"operation", "read", "resid", "~G_v1_Namespace|~X|test", "dry_run", true, "groups", 3})
+	want := `operation="read" resid="~G_v1_Namespace|~X|test" dry_run=true groups=3`
+	if got != want {
+		t.Errorf("TestFormatLogFields: got: %s, want: %s.", got, want)
+	}
+}
+
+func TestRedactPatch(t *testing.T) {
+	secret := k8sschema.GroupKind{Kind: "Secret"}
+	patch := `{"data":{"password":"c2VjcmV0","removed":null},"stringData":{"user":"admin"},"metadata":{"annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{\"apiVersion\":\"v1\",\"kind\":\"Secret\",\"metadata\":{\"name\":\"test\"},\"data\":{\"password\":\"c2VjcmV0\"}}"}}}`
+
+	got := redactPatch(secret, []byte(patch))
+	for _, plain := range []string{"c2VjcmV0", "admin"} {
+		if strings.Contains(got, plain) {
+			t.Errorf("TestRedactPatch: expected '%s' to be redacted, got: %s.", plain, got)
+		}
+	}
+	if !strings.Contains(got, `"removed":null`) {
+		t.Errorf("TestRedactPatch: expected removed keys to be kept, got: %s.", got)
+	}
+	if !strings.Contains(got, redactedValuePrefix) {
+		t.Errorf("TestRedactPatch: expected hashed values, got: %s.", got)
+	}
+
+	got = redactPatch(secret, []byte(`{`))
+	if got != "(sensitive patch)" {
+		t.Errorf("TestRedactPatch: expected invalid patches to be redacted, got: %s.", got)
+	}
+
+	configMapPatch := `{"data":{"key":"value"}}`
+	got = redactPatch(k8sschema.GroupKind{Kind: "ConfigMap"}, []byte(configMapPatch))
+	if got != configMapPatch {
+		t.Errorf("TestRedactPatch: expected other kinds to be unchanged, got: %s, want: %s.", got, configMapPatch)
+	}
+}
+
+func TestErrorLogFields(t *testing.T) {
+	configMap := k8sschema.GroupKind{Kind: "ConfigMap"}
+	if errorLogFields(configMap, nil) != nil {
+		t.Errorf("TestErrorLogFields: expected no fields without error")
+	}
+
+	got := formatLogFields(errorLogFields(configMap, errors.New("failed")))
+	want := `reason="" error="failed"`
+	if got != want {
+		t.Errorf("TestErrorLogFields: got: %s, want: %s.", got, want)
+	}
+}
+
+func TestRedactError(t *testing.T) {
+	secret := k8sschema.GroupKind{Kind: "Secret"}
+	invalid := k8serrors.NewInvalid(secret, "test", field.ErrorList{
+		field.Invalid(field.NewPath("data").Key("password"), "c2VjcmV0", "invalid value"),
+	})
+
+	got := redactError(secret, invalid)
+	if strings.Contains(got, "c2VjcmV0") {
+		t.Errorf("TestRedactError: expected value to be redacted, got: %s.", got)
+	}
+	want := `(sensitive error) Secret "test": data[password]: FieldValueInvalid`
+	if got != want {
+		t.Errorf("TestRedactError: got: %s, want: %s.", got, want)
+	}
+
+	got = redactError(secret, errors.New("decoding c2VjcmV0 failed"))
+	if got != "(sensitive error)" {
+		t.Errorf("TestRedactError: expected other errors to be redacted, got: %s.", got)
+	}
+
+	got = formatLogFields(errorLogFields(secret, invalid))
+	if !strings.HasPrefix(got, `reason="Invalid" error="(sensitive error)`) {
+		t.Errorf("TestRedactError: expected the reason to be kept, got: %s.", got)
+	}
+
+	configMap := k8sschema.GroupKind{Kind: "ConfigMap"}
+	got = redactError(configMap, invalid)
+	if got != invalid.Error() {
+		t.Errorf("TestRedactError: expected errors of other kinds to be unchanged, got: %s, want: %s.", got, invalid.Error())
+	}
+}
+
+func TestResourceLoggingFake(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	initial := `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"test-fake"},"data":{"password":"` + base64.StdEncoding.EncodeToString([]byte("initial-password")) + `"}}`
+	modified := `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"test-fake"},"data":{"password":"` + base64.StdEncoding.EncodeToString([]byte("modified-password")) + `"}}`
+
+	c := newFakeCluster(t, fakeObject(t, fakeNamespaceManifest))
+	state := createFake(t, c, initial)
+
+	_, err := kustomizationResource().Apply(state, fakeManifestDiff(initial, modified), c.config())
+	if err != nil {
+		t.Fatalf("TestResourceLoggingFake: %s", err)
+	}
+
+	// API errors about invalid values include them
+	third := `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"test-fake"},"data":{"password":"` + base64.StdEncoding.EncodeToString([]byte("invalid-password")) + `"}}`
+	c.failOn("patch", "secrets", k8serrors.NewInvalid(k8sschema.GroupKind{Kind: "Secret"}, "test", field.ErrorList{
+		field.Invalid(field.NewPath("data").Key("password"), base64.StdEncoding.EncodeToString([]byte("invalid-password")), "invalid value"),
+	}))
+	_, err = kustomizationResource().Apply(state, fakeManifestDiff(modified, third), c.config())
+	if err == nil {
+		t.Fatalf("TestResourceLoggingFake: expected invalid patch to fail")
+	}
+
+	logs := buf.String()
+
+	want := []string{
+		`operation="create" resid="~G_v1_Secret|test-fake|test" event="wait" waiting_for="group_version_kind" state="existing"`,
+		`operation="create" resid="~G_v1_Secret|test-fake|test" event="wait" waiting_for="namespace" state="existing"`,
+		`operation="create" resid="~G_v1_Secret|test-fake|test" event="api_call" verb="create" resource="/v1, Resource=secrets" namespace="test-fake" name="test"`,
+		`operation="read" resid="~G_v1_Secret|test-fake|test" event="api_call" verb="get"`,
+		`operation="update" resid="~G_v1_Secret|test-fake|test" event="patch" dry_run=false body=`,
+		`operation="update" resid="~G_v1_Secret|test-fake|test" event="api_call" verb="patch"`,
+		`event="discovery_refresh" groups=`,
+		`verb="patch" resource="/v1, Resource=secrets" namespace="test-fake" name="test"`,
+		`reason="Invalid" error="(sensitive error) Secret \"test\": data[password]: FieldValueInvalid"`,
+	}
+	for _, w := range want {
+		if !strings.Contains(logs, w) {
+			t.Errorf("TestResourceLoggingFake: expected log containing '%s', got:\n%s", w, logs)
+		}
+	}
+
+	for _, plain := range []string{"initial-password", "modified-password", "invalid-password"} {
+		encoded := base64.StdEncoding.EncodeToString([]byte(plain))
+		if strings.Contains(logs, encoded) {
+			t.Errorf("TestResourceLoggingFake: expected Secret value '%s' to be redacted, got:\n%s", plain, logs)
+		}
+	}
+}
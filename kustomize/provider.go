@@ -7,7 +7,9 @@ import (
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -22,11 +24,21 @@ import (
 // Config ...
 type Config struct {
 	Client                 dynamic.Interface
+	Discovery              discovery.DiscoveryInterface
 	CachedGroupVersionKind cachedGroupVersionKind
+	HelmPath               string
+	SopsPath               string
+	SopsAgeKey             string
+	ExecFunctionAllowlist  []string
+	ExecFunctionEnv        []string
 }
 
 const kubeconfigDefault = "~/.kube/config"
 
+const helmPathDefault = "helm"
+
+const sopsPathDefault = "sops"
+
 // Provider ...
 func Provider() *schema.Provider {
 	p := &schema.Provider{
@@ -37,6 +49,7 @@ func Provider() *schema.Provider {
 		DataSourcesMap: map[string]*schema.Resource{
 			"kustomization": dataSourceKustomization(),
 			"kustomization_template": dataSourceKustomizationTemplate(),
+			"kustomization_overlay": dataSourceKustomizationOverlay(),
 		},
 
 		Schema: map[string]*schema.Schema{
@@ -63,52 +76,131 @@ func Provider() *schema.Provider {
 				Default:     "",
 				Description: "Context to use in kubeconfig with multiple contexts, if not specified the default context is to be used.",
 			},
+			"helm_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("HELM_PATH", helmPathDefault),
+				Description: fmt.Sprintf("Path to the helm binary used to inflate helm charts. Defaults to '%s'.", helmPathDefault),
+			},
+			"sops_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("SOPS_PATH", sopsPathDefault),
+				Description: fmt.Sprintf("Path to the sops binary used to decrypt SOPS encrypted files. Defaults to '%s'.", sopsPathDefault),
+			},
+			"sops_age_key": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("SOPS_AGE_KEY", ""),
+				Description: "Age private key used to decrypt SOPS encrypted files. Other sops key sources, e.g. SOPS_AGE_KEY_FILE or the GnuPG keyring, are used from the environment.",
+			},
+			"exec_function_allowlist": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Paths of exec KRM function binaries kustomizations are allowed to run. Exec functions are disabled if empty.",
+			},
+			"exec_function_env": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Names of environment variables passed through to exec KRM functions.",
+			},
 		},
 	}
 
 	p.ConfigureFunc = func(d *schema.ResourceData) (interface{}, error) {
-		var data []byte
-		var config *rest.Config
-		var err error
-
-		context := d.Get("context").(string)
-		raw := d.Get("kubeconfig_raw").(string)
-		data = []byte(raw)
+		config := newRESTConfig(
+			d.Get("kubeconfig_raw").(string),
+			d.Get("kubeconfig_path").(string),
+			d.Get("context").(string))
 
-		// try to get a config from kubeconfig_raw
-		config, err = getClientConfig(data, context)
+		client, dc, err := newKubernetesClients(config)
 		if err != nil {
-			// if kubeconfig_raw did not work, try kubeconfig_path
-			path := d.Get("kubeconfig_path").(string)
-			data, _ = readKubeconfigFile(path)
-
-			config, err = getClientConfig(data, context)
-			if err != nil {
-				// if neither worked we fall back to an empty default config
-				config = &rest.Config{}
-			}
+			return nil, err
 		}
 
-		// Increase QPS and Burst rate limits
-		config.QPS = 120
-		config.Burst = 240
+		cgvk := newCachedGroupVersionKind(dc)
+
+		return &Config{
+			Client:                 client,
+			Discovery:              dc,
+			CachedGroupVersionKind: cgvk,
+			HelmPath:               d.Get("helm_path").(string),
+			SopsPath:               d.Get("sops_path").(string),
+			SopsAgeKey:             d.Get("sops_age_key").(string),
+			ExecFunctionAllowlist:  expandStringList(d.Get("exec_function_allowlist").([]interface{})),
+			ExecFunctionEnv:        expandStringList(d.Get("exec_function_env").([]interface{})),
+		}, nil
+	}
 
-		client, err := dynamic.NewForConfig(config)
-		if err != nil {
-			return nil, err
-		}
+	return p
+}
 
-		clientset, err := kubernetes.NewForConfig(config)
+// newRESTConfig returns the client config from the raw kubeconfig,
+// or if that does not work, from the kubeconfig file at path
+func newRESTConfig(raw string, path string, context string) *rest.Config {
+	var data []byte
+	var config *rest.Config
+	var err error
+
+	data = []byte(raw)
+
+	// try to get a config from kubeconfig_raw
+	config, err = getClientConfig(data, context)
+	if err != nil {
+		// if kubeconfig_raw did not work, try kubeconfig_path
+		data, _ = readKubeconfigFile(path)
+
+		config, err = getClientConfig(data, context)
 		if err != nil {
-			return nil, err
+			// if neither worked we fall back to an empty default config
+			config = &rest.Config{}
 		}
+	}
 
-		cgvk := newCachedGroupVersionKind(clientset)
+	// Increase QPS and Burst rate limits
+	config.QPS = 120
+	config.Burst = 240
 
-		return &Config{client, cgvk}, nil
+	return config
+}
+
+// loadRESTConfig returns the client config from the kubeconfig file
+// at path, unlike newRESTConfig it fails if the file is unusable,
+// instead of falling back to an empty config
+func loadRESTConfig(path string, context string) (*rest.Config, error) {
+	data, err := readKubeconfigFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading kubeconfig '%s' failed: %s", path, err)
 	}
 
-	return p
+	config, err := getClientConfig(data, context)
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig '%s' failed: %s", path, err)
+	}
+
+	// Increase QPS and Burst rate limits
+	config.QPS = 120
+	config.Burst = 240
+
+	return config, nil
+}
+
+// newKubernetesClients returns the dynamic and discovery clients for config
+func newKubernetesClients(config *rest.Config) (dynamic.Interface, discovery.DiscoveryInterface, error) {
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, clientset.Discovery(), nil
 }
 
 func readKubeconfigFile(s string) ([]byte, error) {
@@ -144,23 +236,25 @@ func getClientConfig(data []byte, context string) (*rest.Config, error) {
 	return clientConfig.ClientConfig()
 }
 
-func newCachedGroupVersionKind(cs *kubernetes.Clientset) cachedGroupVersionKind {
+func newCachedGroupVersionKind(dc discovery.DiscoveryInterface) cachedGroupVersionKind {
 	cache := cache.New(1*time.Minute, 1*time.Minute)
 
 	return cachedGroupVersionKind{
-		cs:    cs,
+		dc:    dc,
 		cache: cache,
 	}
 }
 
 type cachedGroupVersionKind struct {
-	cs    *kubernetes.Clientset
+	dc    discovery.DiscoveryInterface
 	cache *cache.Cache
 }
 
 const APIGroupResourcesCacheKey string = "restmapper.GetAPIGroupResources"
 
-func (c cachedGroupVersionKind) getGVR(gvk k8sschema.GroupVersionKind, refreshCache bool) (gvr k8sschema.GroupVersionResource, err error) {
+// getRESTMapper returns a RESTMapper based on the cached API group
+// resources, discovery is refreshed if refreshCache is true
+func (c cachedGroupVersionKind) getRESTMapper(refreshCache bool) (rm meta.RESTMapper, err error) {
 	var agr []*restmapper.APIGroupResources
 
 	cachedAgr, found := c.cache.Get(APIGroupResourcesCacheKey)
@@ -169,14 +263,23 @@ func (c cachedGroupVersionKind) getGVR(gvk k8sschema.GroupVersionKind, refreshCa
 	}
 
 	if found == false || refreshCache == true {
-		agr, err = restmapper.GetAPIGroupResources(c.cs.Discovery())
+		start := time.Now()
+		agr, err = restmapper.GetAPIGroupResources(c.dc)
+		logDiscoveryRefresh(len(agr), start, err)
 		if err != nil {
-			return gvr, fmt.Errorf("discovering API group resources failed: %s", err)
+			return nil, fmt.Errorf("discovering API group resources failed: %s", err)
 		}
 		c.cache.Set(APIGroupResourcesCacheKey, agr, cache.DefaultExpiration)
 	}
 
-	rm := restmapper.NewDiscoveryRESTMapper(agr)
+	return restmapper.NewDiscoveryRESTMapper(agr), nil
+}
+
+func (c cachedGroupVersionKind) getGVR(gvk k8sschema.GroupVersionKind, refreshCache bool) (gvr k8sschema.GroupVersionResource, err error) {
+	rm, err := c.getRESTMapper(refreshCache)
+	if err != nil {
+		return gvr, err
+	}
 
 	gk := k8sschema.GroupKind{Group: gvk.Group, Kind: gvk.Kind}
 	mapping, err := rm.RESTMapping(gk, gvk.Version)
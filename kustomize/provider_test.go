@@ -1,6 +1,10 @@
 package kustomize
 
 import (
+	"fmt"
+	"os"
+	"testing"
+
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/terraform"
 )
@@ -14,3 +18,16 @@ func init() {
 		"kustomization": testAccProvider,
 	}
 }
+
+func TestMain(m *testing.M) {
+	teardown, err := setupFakeAPIServer()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "starting fake API server failed: %s\n", err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	teardown()
+
+	os.Exit(code)
+}
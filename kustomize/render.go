@@ -0,0 +1,285 @@
+package kustomize
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const renderUsage = `Usage: terraform-provider-kustomization render [options] [path]
+
+Builds a kustomization with the same logic and options as the provider's
+data sources and prints the resulting ids and manifests. Secret values
+are redacted, unless -include-sensitive is set.
+
+Without -template, path is built like the kustomization data source's
+path. With -template, the file is built like the kustomization_template
+data source's kustomization, with paths relative to -base-dir.
+
+Options:
+`
+
+// stringListFlag is a flag that can be repeated
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringListFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// stringMapFlag is a repeatable KEY=VALUE flag
+type stringMapFlag map[string]interface{}
+
+func (f stringMapFlag) String() string {
+	var pairs []string
+	for k, v := range f {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func (f stringMapFlag) Set(v string) error {
+	parts := strings.SplitN(v, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("expected KEY=VALUE, got: '%s'", v)
+	}
+	f[parts[0]] = parts[1]
+
+	return nil
+}
+
+// renderOutput is the JSON output of the render subcommand
+type renderOutput struct {
+	IDs       []string                   `json:"ids"`
+	Manifests map[string]json.RawMessage `json:"manifests"`
+}
+
+// buildFlags are the options of the subcommands,
+// that build a kustomization like the data sources
+type buildFlags struct {
+	template              string
+	baseDir               string
+	detectInlineContent   bool
+	namespace             string
+	namePrefix            string
+	nameSuffix            string
+	variables             stringMapFlag
+	variablesStrict       bool
+	variablesFiles        stringListFlag
+	helmPath              string
+	sopsPath              string
+	execFunctionAllowlist stringListFlag
+	execFunctionEnv       stringListFlag
+}
+
+func addBuildFlags(fs *flag.FlagSet) *buildFlags {
+	f := &buildFlags{variables: make(stringMapFlag)}
+
+	fs.StringVar(&f.template, "template", "", "Path of a file with the kustomization of a kustomization_template.")
+	fs.StringVar(&f.baseDir, "base-dir", "", "Directory the template's paths are resolved against. Defaults to the template's directory.")
+	fs.BoolVar(&f.detectInlineContent, "detect-inline-content", false, "Treat template strings that can not be loaded as paths as inline content.")
+
+	fs.StringVar(&f.namespace, "namespace", "", "Namespace to set on all resources. Only supported without -template.")
+	fs.StringVar(&f.namePrefix, "name-prefix", "", "Prefix to prepend to all resource names. Only supported without -template.")
+	fs.StringVar(&f.nameSuffix, "name-suffix", "", "Suffix to append to all resource names. Only supported without -template.")
+
+	fs.Var(f.variables, "var", "Variable to substitute, as KEY=VALUE. Can be repeated.")
+	fs.BoolVar(&f.variablesStrict, "var-strict", false, "Fail on references to undefined variables.")
+	fs.Var(&f.variablesFiles, "var-files", "Glob of the files variables are substituted in. Can be repeated.")
+
+	fs.StringVar(&f.helmPath, "helm-path", envDefault("HELM_PATH", helmPathDefault), "Path to the helm binary used to inflate helm charts.")
+	fs.StringVar(&f.sopsPath, "sops-path", envDefault("SOPS_PATH", sopsPathDefault), "Path to the sops binary used to decrypt SOPS encrypted files.")
+	fs.Var(&f.execFunctionAllowlist, "exec-function", "Path of an exec KRM function binary the kustomization is allowed to run. Can be repeated.")
+	fs.Var(&f.execFunctionEnv, "exec-function-env", "Name of an environment variable passed through to exec KRM functions. Can be repeated.")
+
+	return f
+}
+
+// build builds the template, or the path given as the
+// flag set's argument, with the data sources' Read function
+func (f *buildFlags) build(fs *flag.FlagSet, m *Config) (*schema.ResourceData, error) {
+	m.HelmPath = f.helmPath
+	m.SopsPath = f.sopsPath
+	m.SopsAgeKey = os.Getenv("SOPS_AGE_KEY")
+	m.ExecFunctionAllowlist = f.execFunctionAllowlist
+	m.ExecFunctionEnv = f.execFunctionEnv
+
+	var r *schema.Resource
+	attrs := map[string]interface{}{
+		"variables":        map[string]interface{}(f.variables),
+		"variables_strict": f.variablesStrict,
+		"variables_files":  []string(f.variablesFiles),
+	}
+
+	if f.template != "" {
+		if fs.NArg() != 0 {
+			return nil, fmt.Errorf("expected no path with -template, got: %s", strings.Join(fs.Args(), " "))
+		}
+		if f.namespace != "" || f.namePrefix != "" || f.nameSuffix != "" {
+			return nil, fmt.Errorf("-namespace, -name-prefix and -name-suffix are not supported with -template, set them in the kustomization")
+		}
+
+		kustomization, err := ioutil.ReadFile(f.template)
+		if err != nil {
+			return nil, fmt.Errorf("reading template failed: %s", err)
+		}
+		baseDir := f.baseDir
+		if baseDir == "" {
+			baseDir = filepath.Dir(f.template)
+		}
+
+		r = dataSourceKustomizationTemplate()
+		attrs["kustomization"] = string(kustomization)
+		attrs["base_dir"] = baseDir
+		attrs["detect_inline_content"] = f.detectInlineContent
+	} else {
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return nil, fmt.Errorf("expected exactly one path, got: %d", fs.NArg())
+		}
+
+		r = dataSourceKustomization()
+		attrs["path"] = fs.Arg(0)
+		attrs["namespace"] = f.namespace
+		attrs["name_prefix"] = f.namePrefix
+		attrs["name_suffix"] = f.nameSuffix
+	}
+
+	d := r.Data(nil)
+	for k, v := range attrs {
+		err := d.Set(k, v)
+		if err != nil {
+			return nil, fmt.Errorf("setting '%s' failed: %s", k, err)
+		}
+	}
+
+	err := r.Read(d, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// newSubcommandFlagSet returns a flag set printing usage and its defaults
+func newSubcommandFlagSet(name string, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// Render implements the render subcommand, it builds a kustomization
+// like the data sources do, and writes the ids and manifests to w
+func Render(args []string, w io.Writer) error {
+	fs := newSubcommandFlagSet("render", renderUsage)
+	output := fs.String("output", "yaml", "Output format, yaml or json.")
+	includeSensitive := fs.Bool("include-sensitive", false, "Print Secret values instead of redacting them.")
+	bf := addBuildFlags(fs)
+
+	err := fs.Parse(args)
+	if err == flag.ErrHelp {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output != "yaml" && *output != "json" {
+		return fmt.Errorf("unsupported output format '%s', expected yaml or json", *output)
+	}
+
+	d, err := bf.build(fs, &Config{})
+	if err != nil {
+		return err
+	}
+
+	return writeRenderOutput(w, d, *output, *includeSensitive)
+}
+
+// envDefault returns the value of the environment variable k,
+// or def if it is not set, like schema.EnvDefaultFunc
+func envDefault(k string, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+
+	return def
+}
+
+func writeRenderOutput(w io.Writer, d *schema.ResourceData, output string, includeSensitive bool) error {
+	manifests := make(map[string]string)
+	for id, m := range d.Get("manifests").(map[string]interface{}) {
+		manifests[id] = m.(string)
+	}
+	for id, m := range d.Get("sensitive_manifests").(map[string]interface{}) {
+		manifest := m.(string)
+		if !includeSensitive {
+			redacted, err := redactSecretManifest(manifest)
+			if err != nil {
+				return fmt.Errorf("redacting '%s' failed: %s", id, err)
+			}
+			manifest = redacted
+		}
+		manifests[id] = manifest
+	}
+
+	var ids []string
+	for _, id := range d.Get("ids").(*schema.Set).List() {
+		ids = append(ids, id.(string))
+	}
+	sort.Strings(ids)
+
+	if output == "json" {
+		out := renderOutput{
+			IDs:       ids,
+			Manifests: make(map[string]json.RawMessage),
+		}
+		for id, m := range manifests {
+			out.Manifests[id] = json.RawMessage(m)
+		}
+
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+
+		return err
+	}
+
+	for i, id := range ids {
+		data, err := yaml.JSONToYAML([]byte(manifests[id]))
+		if err != nil {
+			return fmt.Errorf("converting '%s' to yaml failed: %s", id, err)
+		}
+
+		if i > 0 {
+			fmt.Fprint(w, "---\n")
+		}
+		_, err = fmt.Fprintf(w, "# %s\n%s", id, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package kustomize
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	var out bytes.Buffer
+	err := Render([]string{"-namespace", "test-render", "../test_kustomizations/basic/initial"}, &out)
+	if err != nil {
+		t.Fatalf("TestRender: %s", err)
+	}
+
+	for _, want := range []string{
+		"# apps_v1_Deployment|test-render|test\napiVersion: apps/v1\nkind: Deployment\n",
+		"---\n# ~G_v1_Service|test-render|test\n",
+		"  namespace: test-render\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("TestRender: missing from output, got: %s, want: %s.", out.String(), want)
+		}
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	var out bytes.Buffer
+	err := Render([]string{"-output", "json", "../test_kustomizations/secret"}, &out)
+	if err != nil {
+		t.Fatalf("TestRenderJSON: %s", err)
+	}
+
+	var result renderOutput
+	err = json.Unmarshal(out.Bytes(), &result)
+	if err != nil {
+		t.Fatalf("TestRenderJSON: %s", err)
+	}
+
+	if len(result.IDs) != 2 || len(result.Manifests) != 2 {
+		t.Errorf("TestRenderJSON: expected 2 ids and manifests, got: %d, %d.", len(result.IDs), len(result.Manifests))
+	}
+
+	secret := string(result.Manifests["~G_v1_Secret|test-secret|test-bbch668m4m"])
+	if !strings.Contains(secret, redactedValuePrefix) {
+		t.Errorf("TestRenderJSON: expected Secret data to be redacted, got: %s.", secret)
+	}
+
+	out.Reset()
+	err = Render([]string{"-output", "json", "-include-sensitive", "../test_kustomizations/secret"}, &out)
+	if err != nil {
+		t.Fatalf("TestRenderJSON: %s", err)
+	}
+	if !strings.Contains(out.String(), `"password": "c2VjcmV0"`) {
+		t.Errorf("TestRenderJSON: expected Secret data with -include-sensitive, got: %s.", out.String())
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kustomize-render")
+	if err != nil {
+		t.Fatalf("TestRenderTemplate: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	template := filepath.Join(dir, "kustomization.yaml")
+	err = ioutil.WriteFile(template, []byte("resources:\n- namespace.yaml\n- _example_app\nnamePrefix: ${PREFIX}-\n"), 0644)
+	if err != nil {
+		t.Fatalf("TestRenderTemplate: %s", err)
+	}
+
+	var out bytes.Buffer
+	err = Render([]string{"-template", template, "-base-dir", "../test_kustomizations/template", "-var", "PREFIX=render"}, &out)
+	if err != nil {
+		t.Fatalf("TestRenderTemplate: %s", err)
+	}
+
+	if !strings.Contains(out.String(), "# apps_v1_Deployment|~X|render-test\n") {
+		t.Errorf("TestRenderTemplate: expected prefixed Deployment, got: %s.", out.String())
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-output", "xml", "../test_kustomizations/basic/initial"}, "unsupported output format 'xml'"},
+		{[]string{"-var", "NOVALUE", "../test_kustomizations/basic/initial"}, "expected KEY=VALUE"},
+		{[]string{"-template", "kustomization.yaml", "-namespace", "test"}, "not supported with -template"},
+		{[]string{"../test_kustomizations/missing"}, "kustomizationBuild"},
+	}
+
+	for _, c := range cases {
+		var out bytes.Buffer
+		err := Render(c.args, &out)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("TestRenderErrors: %v: got: %v, want: %s.", c.args, err, c.want)
+		}
+	}
+}
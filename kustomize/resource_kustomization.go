@@ -5,20 +5,35 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
-
 	"sigs.k8s.io/kustomize/api/resid"
 
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 )
 
 func kustomizationResource() *schema.Resource {
 	return &schema.Resource{
+		SchemaVersion: 2,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    kustomizationResourceV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: kustomizationResourceStateUpgradeV0,
+			},
+			{
+				Version: 1,
+				Type:    kustomizationResourceV1().CoreConfigSchema().ImpliedType(),
+				Upgrade: kustomizationResourceStateUpgradeV1,
+			},
+		},
+
 		Create:        kustomizationResourceCreate,
 		Read:          kustomizationResourceRead,
 		Exists:        kustomizationResourceExists,
@@ -32,8 +47,46 @@ func kustomizationResource() *schema.Resource {
 
 		Schema: map[string]*schema.Schema{
 			"manifest": &schema.Schema{
-				Type:     schema.TypeString,
-				Required: true,
+				Type:      schema.TypeString,
+				Required:  true,
+				StateFunc: redactSecretManifestStateFunc,
+			},
+			"omit_secret_data_in_last_applied": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Store only hashes of Secret data and stringData values in the last applied configuration annotation. Changing it replaces Secrets, because the state only has the hashes to patch the annotation with. It has no effect on other kinds.",
+			},
+			"replace_on_uid_change": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Plan to replace the object, if it was deleted and recreated outside Terraform. By default, the new object is accepted: its UID silently becomes the id, and only a warning is logged.",
+			},
+			"uid": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "UID of the live object, differs from the id if the object was replaced outside Terraform.",
+			},
+			"resid": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Kustomize resource ID of the manifest, like the ids of the kustomization data sources.",
+			},
+			"gvk": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Group, version and kind of the manifest, in the format of the resid.",
+			},
+			"namespace": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Namespace of the manifest, empty for cluster scoped kinds.",
+			},
+			"name": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Name of the manifest.",
 			},
 		},
 	}
@@ -48,6 +101,7 @@ func kustomizationResourceCreate(d *schema.ResourceData, m interface{}) error {
 	if err != nil {
 		return fmt.Errorf("ResourceCreate: %s", err)
 	}
+	logger := newResourceLogger("create", u)
 
 	stateConf := &resource.StateChangeConf{
 		Target:  []string{"existing"},
@@ -57,9 +111,11 @@ func kustomizationResourceCreate(d *schema.ResourceData, m interface{}) error {
 			// CRDs: wait for GroupVersionKind to exist
 			gvr, err := cgvk.getGVR(u.GroupVersionKind(), true)
 			if err != nil {
+				logger.wait("group_version_kind", "pending", err)
 				return nil, "pending", nil
 			}
 
+			logger.wait("group_version_kind", "existing", nil)
 			return gvr, "existing", nil
 		},
 	}
@@ -74,7 +130,11 @@ func kustomizationResourceCreate(d *schema.ResourceData, m interface{}) error {
 	gvr := gvrResp.(k8sschema.GroupVersionResource)
 	namespace := u.GetNamespace()
 
-	setLastAppliedConfig(u, srcJSON)
+	lac, err := lastAppliedConfigFor(srcJSON, d.Get("omit_secret_data_in_last_applied").(bool))
+	if err != nil {
+		return fmt.Errorf("ResourceCreate: %s", err)
+	}
+	setLastAppliedConfig(u, lac)
 
 	if namespace != "" {
 		// wait for the namespace to exist
@@ -92,16 +152,21 @@ func kustomizationResourceCreate(d *schema.ResourceData, m interface{}) error {
 			Pending: []string{"pending"},
 			Timeout: d.Timeout(schema.TimeoutCreate),
 			Refresh: func() (interface{}, string, error) {
+				start := time.Now()
 				resp, err := client.
 					Resource(nsGvr).
 					Get(context.TODO(), namespace, k8smetav1.GetOptions{})
+				logger.apiCall("get", nsGvr, "", namespace, start, err)
 				if err != nil {
 					if k8serrors.IsNotFound(err) {
+						logger.wait("namespace", "pending", nil)
 						return nil, "pending", nil
 					}
+					logger.wait("namespace", "", err)
 					return nil, "", err
 				}
 
+				logger.wait("namespace", "existing", nil)
 				return resp, "existing", nil
 			},
 		}
@@ -114,10 +179,12 @@ func kustomizationResourceCreate(d *schema.ResourceData, m interface{}) error {
 		}
 	}
 
+	start := time.Now()
 	resp, err := client.
 		Resource(gvr).
 		Namespace(namespace).
 		Create(context.TODO(), u, k8smetav1.CreateOptions{})
+	logger.apiCall("create", gvr, namespace, u.GetName(), start, err)
 	if err != nil {
 		return fmt.Errorf("ResourceCreate: creating '%s' failed: %s", gvr, err)
 	}
@@ -125,7 +192,10 @@ func kustomizationResourceCreate(d *schema.ResourceData, m interface{}) error {
 	id := string(resp.GetUID())
 	d.SetId(id)
 
-	d.Set("manifest", getLastAppliedConfig(resp))
+	err = setManifestFromLastAppliedConfig(d, resp)
+	if err != nil {
+		return fmt.Errorf("ResourceCreate: %s", err)
+	}
 
 	return kustomizationResourceRead(d, m)
 }
@@ -138,6 +208,7 @@ func kustomizationResourceRead(d *schema.ResourceData, m interface{}) error {
 	if err != nil {
 		return fmt.Errorf("ResourceRead: %s", err)
 	}
+	logger := newResourceLogger("read", u)
 
 	gvr, err := cgvk.getGVR(u.GroupVersionKind(), false)
 	if err != nil {
@@ -146,18 +217,38 @@ func kustomizationResourceRead(d *schema.ResourceData, m interface{}) error {
 	namespace := u.GetNamespace()
 	name := u.GetName()
 
+	start := time.Now()
 	resp, err := client.
 		Resource(gvr).
 		Namespace(namespace).
 		Get(context.TODO(), name, k8smetav1.GetOptions{})
+	logger.apiCall("get", gvr, namespace, name, start, err)
 	if err != nil {
 		return fmt.Errorf("ResourceRead: reading '%s' failed: %s", gvr, err)
 	}
 
 	id := string(resp.GetUID())
-	d.SetId(id)
+	if d.Id() != "" && d.Id() != id {
+		replace := d.Get("replace_on_uid_change").(bool)
+		logger.warn("uid_changed",
+			"old_uid", d.Id(),
+			"new_uid", id,
+			"replace", replace)
+
+		// by default the new object is accepted, otherwise keep
+		// the ID, so the plan can detect the replacement by
+		// comparing it to the uid
+		if !replace {
+			d.SetId(id)
+		}
+	} else {
+		d.SetId(id)
+	}
 
-	d.Set("manifest", getLastAppliedConfig(resp))
+	err = setManifestFromLastAppliedConfig(d, resp)
+	if err != nil {
+		return fmt.Errorf("ResourceRead: %s", err)
+	}
 
 	return nil
 }
@@ -168,10 +259,49 @@ func kustomizationResourceDiff(d *schema.ResourceDiff, m interface{}) error {
 
 	originalJSON, modifiedJSON := d.GetChange("manifest")
 
+	// the object was replaced outside Terraform since it was
+	// created or imported, replace it to own the object again
+	uid := d.Get("uid").(string)
+	if d.Get("replace_on_uid_change").(bool) && d.Id() != "" && uid != "" && uid != d.Id() {
+		err := d.SetNewComputed("uid")
+		if err != nil {
+			return fmt.Errorf("ResourceDiff: %s", err)
+		}
+		err = d.ForceNew("uid")
+		if err != nil {
+			return fmt.Errorf("ResourceDiff: %s", err)
+		}
+	}
+
+	// only Secrets store hashes in the annotation, other
+	// kinds are not replaced when the option changes
+	if d.Id() != "" && d.HasChange("omit_secret_data_in_last_applied") {
+		manifest := modifiedJSON.(string)
+		if manifest == "" {
+			manifest = originalJSON.(string)
+		}
+		u, err := parseJSON(manifest)
+		if err != nil {
+			return fmt.Errorf("ResourceDiff: %s", err)
+		}
+		gvk := u.GroupVersionKind()
+		if isSensitiveGroupKind(gvk.Group, gvk.Kind) {
+			err = d.ForceNew("omit_secret_data_in_last_applied")
+			if err != nil {
+				return fmt.Errorf("ResourceDiff: %s", err)
+			}
+		}
+	}
+
 	if !d.HasChange("manifest") {
 		return nil
 	}
 
+	err := setDiffComputedAttributes(d, modifiedJSON.(string))
+	if err != nil {
+		return fmt.Errorf("ResourceDiff: %s", err)
+	}
+
 	if originalJSON.(string) == "" {
 		return nil
 	}
@@ -180,6 +310,7 @@ func kustomizationResourceDiff(d *schema.ResourceDiff, m interface{}) error {
 	if err != nil {
 		return fmt.Errorf("ResourceDiff: %s", err)
 	}
+	logger := newResourceLogger("diff", u)
 
 	gvr, err := cgvk.getGVR(u.GroupVersionKind(), false)
 	if err != nil {
@@ -192,6 +323,8 @@ func kustomizationResourceDiff(d *schema.ResourceDiff, m interface{}) error {
 		originalJSON.(string),
 		modifiedJSON.(string),
 		true,
+		d.Get("omit_secret_data_in_last_applied").(bool),
+		logger,
 		m)
 	if err != nil {
 		return fmt.Errorf("ResourceDiff: %s", err)
@@ -201,13 +334,16 @@ func kustomizationResourceDiff(d *schema.ResourceDiff, m interface{}) error {
 	if err != nil {
 		return fmt.Errorf("ResourceDiff: %s", err)
 	}
+	logger.patch(u.GroupVersionKind().GroupKind(), patch, true)
 
 	dryRunPatch := k8smetav1.PatchOptions{DryRun: []string{k8smetav1.DryRunAll}}
 
+	start := time.Now()
 	_, err = client.
 		Resource(gvr).
 		Namespace(namespace).
 		Patch(context.TODO(), name, k8stypes.StrategicMergePatchType, patch, dryRunPatch)
+	logger.apiCall("patch", gvr, namespace, name, start, err)
 	if err != nil {
 		//
 		//
@@ -233,6 +369,33 @@ func kustomizationResourceDiff(d *schema.ResourceDiff, m interface{}) error {
 	return nil
 }
 
+// setDiffComputedAttributes plans the computed attributes
+// for the new manifest, if it is known
+func setDiffComputedAttributes(d *schema.ResourceDiff, modifiedJSON string) error {
+	if !d.NewValueKnown("manifest") || modifiedJSON == "" {
+		for _, k := range []string{"resid", "gvk", "namespace", "name"} {
+			err := d.SetNewComputed(k)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	u, err := parseJSON(modifiedJSON)
+	if err != nil {
+		return err
+	}
+	for k, v := range computedAttributesFor(u) {
+		err := d.SetNew(k, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func kustomizationResourceExists(d *schema.ResourceData, m interface{}) (bool, error) {
 	client := m.(*Config).Client
 	cgvk := m.(*Config).CachedGroupVersionKind
@@ -241,6 +404,7 @@ func kustomizationResourceExists(d *schema.ResourceData, m interface{}) (bool, e
 	if err != nil {
 		return false, fmt.Errorf("ResourceExists: %s", err)
 	}
+	logger := newResourceLogger("exists", u)
 
 	gvr, err := cgvk.getGVR(u.GroupVersionKind(), false)
 	if err != nil {
@@ -251,10 +415,12 @@ func kustomizationResourceExists(d *schema.ResourceData, m interface{}) (bool, e
 	namespace := u.GetNamespace()
 	name := u.GetName()
 
+	start := time.Now()
 	_, err = client.
 		Resource(gvr).
 		Namespace(namespace).
 		Get(context.TODO(), name, k8smetav1.GetOptions{})
+	logger.apiCall("get", gvr, namespace, name, start, err)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			return false, nil
@@ -271,6 +437,12 @@ func kustomizationResourceUpdate(d *schema.ResourceData, m interface{}) error {
 
 	originalJSON, modifiedJSON := d.GetChange("manifest")
 
+	// options that only affect future changes, or only
+	// Secrets, are stored without updating the object
+	if !d.HasChange("manifest") && (d.HasChange("omit_secret_data_in_last_applied") || d.HasChange("replace_on_uid_change")) {
+		return nil
+	}
+
 	if !d.HasChange("manifest") {
 		msg := fmt.Sprintf(
 			"Update called without change. old: %s, new: %s",
@@ -283,6 +455,7 @@ func kustomizationResourceUpdate(d *schema.ResourceData, m interface{}) error {
 	if err != nil {
 		return fmt.Errorf("ResourceUpdate: %s", err)
 	}
+	logger := newResourceLogger("update", u)
 
 	gvr, err := cgvk.getGVR(u.GroupVersionKind(), false)
 	if err != nil {
@@ -295,6 +468,8 @@ func kustomizationResourceUpdate(d *schema.ResourceData, m interface{}) error {
 		originalJSON.(string),
 		modifiedJSON.(string),
 		false,
+		d.Get("omit_secret_data_in_last_applied").(bool),
+		logger,
 		m)
 	if err != nil {
 		return fmt.Errorf("ResourceUpdate: %s", err)
@@ -304,11 +479,14 @@ func kustomizationResourceUpdate(d *schema.ResourceData, m interface{}) error {
 	if err != nil {
 		return fmt.Errorf("ResourceUpdate: %s", err)
 	}
+	logger.patch(u.GroupVersionKind().GroupKind(), patch, false)
 
+	start := time.Now()
 	patchResp, err := client.
 		Resource(gvr).
 		Namespace(namespace).
 		Patch(context.TODO(), name, k8stypes.StrategicMergePatchType, patch, k8smetav1.PatchOptions{})
+	logger.apiCall("patch", gvr, namespace, name, start, err)
 	if err != nil {
 		return fmt.Errorf("ResourceUpdate: patching '%s' failed: %s", gvr, err)
 	}
@@ -316,7 +494,10 @@ func kustomizationResourceUpdate(d *schema.ResourceData, m interface{}) error {
 	id := string(patchResp.GetUID())
 	d.SetId(id)
 
-	d.Set("manifest", getLastAppliedConfig(patchResp))
+	err = setManifestFromLastAppliedConfig(d, patchResp)
+	if err != nil {
+		return fmt.Errorf("ResourceUpdate: %s", err)
+	}
 
 	return kustomizationResourceRead(d, m)
 }
@@ -329,6 +510,7 @@ func kustomizationResourceDelete(d *schema.ResourceData, m interface{}) error {
 	if err != nil {
 		return fmt.Errorf("ResourceDelete: %s", err)
 	}
+	logger := newResourceLogger("delete", u)
 
 	gvr, err := cgvk.getGVR(u.GroupVersionKind(), false)
 	if err != nil {
@@ -339,10 +521,12 @@ func kustomizationResourceDelete(d *schema.ResourceData, m interface{}) error {
 	namespace := u.GetNamespace()
 	name := u.GetName()
 
+	start := time.Now()
 	err = client.
 		Resource(gvr).
 		Namespace(namespace).
 		Delete(context.TODO(), name, k8smetav1.DeleteOptions{})
+	logger.apiCall("delete", gvr, namespace, name, start, err)
 	if err != nil {
 		// Consider not found during deletion a success
 		if k8serrors.IsNotFound(err) {
@@ -358,17 +542,22 @@ func kustomizationResourceDelete(d *schema.ResourceData, m interface{}) error {
 		Pending: []string{"deleting"},
 		Timeout: d.Timeout(schema.TimeoutDelete),
 		Refresh: func() (interface{}, string, error) {
+			start := time.Now()
 			resp, err := client.
 				Resource(gvr).
 				Namespace(namespace).
 				Get(context.TODO(), name, k8smetav1.GetOptions{})
+			logger.apiCall("get", gvr, namespace, name, start, err)
 			if err != nil {
 				if k8serrors.IsNotFound(err) {
+					logger.wait("deletion", "deleted", nil)
 					return nil, "", nil
 				}
+				logger.wait("deletion", "", err)
 				return nil, "", fmt.Errorf("refreshing '%s' state failed: %s", gvr, err)
 			}
 
+			logger.wait("deletion", "deleting", nil)
 			return resp, "deleting", nil
 		},
 	}
@@ -383,36 +572,90 @@ func kustomizationResourceDelete(d *schema.ResourceData, m interface{}) error {
 }
 
 func kustomizationResourceImport(d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
-	client := m.(*Config).Client
-	cgvk := m.(*Config).CachedGroupVersionKind
+	resp, err := getImportObject(d.Id(), m)
+	if err != nil {
+		return nil, fmt.Errorf("ResourceImport: %s", err)
+	}
 
-	rid := resid.FromString(d.Id())
+	id := string(resp.GetUID())
+	d.SetId(id)
 
-	gvk := k8sschema.GroupVersionKind{
-		Group:   rid.Gvk.Group,
-		Version: rid.Gvk.Version,
-		Kind:    rid.Gvk.Kind,
+	manifest := getLastAppliedConfig(resp)
+	if manifest == "" {
+		// objects not created by the provider or kubectl apply
+		manifest, err = manifestFromLiveObject(resp)
+		if err != nil {
+			return nil, fmt.Errorf("ResourceImport: %s", err)
+		}
 	}
-	gvr, err := cgvk.getGVR(gvk, false)
+
+	err = setManifest(d, resp, manifest)
 	if err != nil {
 		return nil, fmt.Errorf("ResourceImport: %s", err)
 	}
 
-	namespace := rid.Namespace
-	name := rid.Name
+	d.Set("omit_secret_data_in_last_applied", false)
+	d.Set("replace_on_uid_change", false)
 
-	resp, err := client.
-		Resource(gvr).
-		Namespace(namespace).
-		Get(context.TODO(), name, k8smetav1.GetOptions{})
+	return []*schema.ResourceData{d}, nil
+}
+
+func setManifestFromLastAppliedConfig(d *schema.ResourceData, u *k8sunstructured.Unstructured) error {
+	lac := getLastAppliedConfig(u)
+	if lac == "" {
+		// objects imported without the annotation keep the
+		// manifest built by the import, until the next apply
+		lac = d.Get("manifest").(string)
+	}
+
+	return setManifest(d, u, lac)
+}
+
+// setManifest sets the manifest, redacted for Secrets,
+// the uid of u and the attributes computed from the manifest
+func setManifest(d *schema.ResourceData, u *k8sunstructured.Unstructured, manifestJSON string) error {
+	manifest, err := redactSecretManifest(manifestJSON)
 	if err != nil {
-		return nil, fmt.Errorf("ResourceImport: reading '%s' failed: %s", gvr, err)
+		return err
 	}
 
-	id := string(resp.GetUID())
-	d.SetId(id)
+	d.Set("manifest", manifest)
+	d.Set("uid", string(u.GetUID()))
 
-	d.Set("manifest", getLastAppliedConfig(resp))
+	if manifest == "" {
+		return nil
+	}
 
-	return []*schema.ResourceData{d}, nil
+	mu, err := parseJSON(manifest)
+	if err != nil {
+		return err
+	}
+	for k, v := range computedAttributesFor(mu) {
+		d.Set(k, v)
+	}
+
+	return nil
+}
+
+// residFor returns the kustomize resource ID of manifest u
+func residFor(u *k8sunstructured.Unstructured) resid.ResId {
+	gvk := u.GroupVersionKind()
+
+	return resid.NewResIdWithNamespace(
+		resid.Gvk{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+		u.GetName(),
+		u.GetNamespace())
+}
+
+// computedAttributesFor returns the values of the computed
+// attributes identifying the resource of manifest u
+func computedAttributesFor(u *k8sunstructured.Unstructured) map[string]interface{} {
+	rid := residFor(u)
+
+	return map[string]interface{}{
+		"resid":     rid.String(),
+		"gvk":       rid.Gvk.String(),
+		"namespace": rid.Namespace,
+		"name":      rid.Name,
+	}
 }
@@ -0,0 +1,451 @@
+package kustomize
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var fakeConfigMapGVR = k8sschema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+const fakeNamespaceManifest = `{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"test-fake"}}`
+
+const fakeConfigMapInitial = `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test","namespace":"test-fake"},"data":{"key":"initial"}}`
+
+const fakeConfigMapModified = `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test","namespace":"test-fake"},"data":{"key":"modified"}}`
+
+// createFake creates manifest in the fake cluster using the
+// resource's Create function and returns the resulting state
+func createFake(t *testing.T, c *fakeCluster, manifest string) *terraform.InstanceState {
+	d := schema.TestResourceDataRaw(t, kustomizationResource().Schema, map[string]interface{}{
+		"manifest": manifest,
+	})
+
+	err := kustomizationResourceCreate(d, c.config())
+	if err != nil {
+		t.Fatalf("createFake: %s", err)
+	}
+
+	return d.State()
+}
+
+// fakeManifestDiff returns a plan changing the manifest
+// in place, without running the resource's CustomizeDiff
+func fakeManifestDiff(old string, new string) *terraform.InstanceDiff {
+	return &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"manifest": {Old: old, New: new},
+		},
+	}
+}
+
+func TestResourceCreateFake(t *testing.T) {
+	c := newFakeCluster(t, fakeObject(t, fakeNamespaceManifest))
+
+	state := createFake(t, c, fakeConfigMapInitial)
+
+	if state.ID == "" {
+		t.Errorf("TestResourceCreateFake: expected ID to be set to the UID")
+	}
+
+	u := c.get(fakeConfigMapGVR, "test-fake", "test")
+	if string(u.GetUID()) != state.ID {
+		t.Errorf("TestResourceCreateFake: unexpected ID, got: %s, want: %s.", state.ID, u.GetUID())
+	}
+	if getLastAppliedConfig(u) != fakeConfigMapInitial {
+		t.Errorf("TestResourceCreateFake: unexpected last applied config, got: %s, want: %s.", getLastAppliedConfig(u), fakeConfigMapInitial)
+	}
+	if state.Attributes["manifest"] != fakeConfigMapInitial {
+		t.Errorf("TestResourceCreateFake: unexpected manifest, got: %s, want: %s.", state.Attributes["manifest"], fakeConfigMapInitial)
+	}
+}
+
+func TestResourceCreateAlreadyExistsFake(t *testing.T) {
+	c := newFakeCluster(t, fakeObject(t, fakeNamespaceManifest))
+	c.failOn("create", "configmaps", k8serrors.NewAlreadyExists(fakeConfigMapGVR.GroupResource(), "test"))
+
+	d := schema.TestResourceDataRaw(t, kustomizationResource().Schema, map[string]interface{}{
+		"manifest": fakeConfigMapInitial,
+	})
+
+	err := kustomizationResourceCreate(d, c.config())
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("TestResourceCreateAlreadyExistsFake: expected already exists error, got: %v.", err)
+	}
+	if d.Id() != "" {
+		t.Errorf("TestResourceCreateAlreadyExistsFake: expected empty ID, got: %s.", d.Id())
+	}
+}
+
+func TestResourceReadFake(t *testing.T) {
+	c := newFakeCluster(t, fakeObject(t, fakeNamespaceManifest))
+	state := createFake(t, c, fakeConfigMapInitial)
+
+	d := kustomizationResource().Data(state)
+	err := kustomizationResourceRead(d, c.config())
+	if err != nil {
+		t.Fatalf("TestResourceReadFake: %s", err)
+	}
+	if d.Id() != state.ID {
+		t.Errorf("TestResourceReadFake: unexpected ID, got: %s, want: %s.", d.Id(), state.ID)
+	}
+
+	c.failOn("get", "configmaps", k8serrors.NewNotFound(fakeConfigMapGVR.GroupResource(), "test"))
+
+	exists, err := kustomizationResourceExists(d, c.config())
+	if err != nil {
+		t.Fatalf("TestResourceReadFake: %s", err)
+	}
+	if exists {
+		t.Errorf("TestResourceReadFake: expected resource to not exist")
+	}
+}
+
+func TestResourceReadWithoutLastAppliedConfigFake(t *testing.T) {
+	const uid = "22222222-2222-2222-2222-222222222222"
+
+	// e.g. imported, the live object has changes outside the manifest
+	live := fakeObject(t, `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test","namespace":"test-fake","uid":"`+uid+`"},"data":{"key":"initial","added":"outside"}}`)
+	c := newFakeCluster(t, fakeObject(t, fakeNamespaceManifest), live)
+
+	d := schema.TestResourceDataRaw(t, kustomizationResource().Schema, map[string]interface{}{
+		"manifest": fakeConfigMapInitial,
+	})
+	d.SetId(uid)
+
+	err := kustomizationResourceRead(d, c.config())
+	if err != nil {
+		t.Fatalf("TestResourceReadWithoutLastAppliedConfigFake: %s", err)
+	}
+	if d.Get("manifest") != fakeConfigMapInitial {
+		t.Errorf("TestResourceReadWithoutLastAppliedConfigFake: expected manifest to be kept, got: %s, want: %s.", d.Get("manifest"), fakeConfigMapInitial)
+	}
+	if d.Get("resid") != "~G_v1_ConfigMap|test-fake|test" {
+		t.Errorf("TestResourceReadWithoutLastAppliedConfigFake: unexpected resid, got: %s, want: %s.", d.Get("resid"), "~G_v1_ConfigMap|test-fake|test")
+	}
+}
+
+func TestResourceDiffFake(t *testing.T) {
+	immutable := k8serrors.NewInvalid(
+		k8sschema.GroupKind{Kind: "ConfigMap"},
+		"test",
+		field.ErrorList{field.Invalid(field.NewPath("data"), "modified", "field is immutable")})
+
+	invalid := k8serrors.NewInvalid(
+		k8sschema.GroupKind{Kind: "ConfigMap"},
+		"test",
+		field.ErrorList{
+			field.Invalid(field.NewPath("data"), "modified", "field is immutable"),
+			field.Invalid(field.NewPath("metadata", "name"), "test", "a DNS-1123 subdomain must consist of lower case alphanumeric characters"),
+		})
+
+	cases := []struct {
+		name        string
+		err         error
+		requiresNew bool
+		wantErr     string
+	}{
+		{"inPlace", nil, false, ""},
+		{"immutable", immutable, true, ""},
+		{"invalid", invalid, false, "DNS-1123"},
+	}
+
+	for _, tc := range cases {
+		c := newFakeCluster(t, fakeObject(t, fakeNamespaceManifest))
+		state := createFake(t, c, fakeConfigMapInitial)
+
+		if tc.err != nil {
+			c.failOn("patch", "configmaps", tc.err)
+		}
+
+		config := terraform.NewResourceConfigRaw(map[string]interface{}{
+			"manifest": fakeConfigMapModified,
+		})
+		diff, err := kustomizationResource().Diff(state, config, c.config())
+
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("TestResourceDiffFake: %s: expected error containing '%s', got: %v.", tc.name, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("TestResourceDiffFake: %s: %s", tc.name, err)
+		}
+
+		if diff.RequiresNew() != tc.requiresNew {
+			t.Errorf("TestResourceDiffFake: %s: unexpected RequiresNew, got: %t, want: %t.", tc.name, diff.RequiresNew(), tc.requiresNew)
+		}
+
+		if !reflect.DeepEqual(c.actions("configmaps"), []string{"create", "get", "get", "patch"}) {
+			t.Errorf("TestResourceDiffFake: %s: unexpected requests, got: %s.", tc.name, c.actions("configmaps"))
+		}
+	}
+}
+
+func TestResourceUpdateFake(t *testing.T) {
+	c := newFakeCluster(t, fakeObject(t, fakeNamespaceManifest))
+	state := createFake(t, c, fakeConfigMapInitial)
+
+	state, err := kustomizationResource().Apply(state, fakeManifestDiff(fakeConfigMapInitial, fakeConfigMapModified), c.config())
+	if err != nil {
+		t.Fatalf("TestResourceUpdateFake: %s", err)
+	}
+
+	u := c.get(fakeConfigMapGVR, "test-fake", "test")
+	if u.Object["data"].(map[string]interface{})["key"] != "modified" {
+		t.Errorf("TestResourceUpdateFake: expected data to be patched, got: %v.", u.Object["data"])
+	}
+	if getLastAppliedConfig(u) != fakeConfigMapModified {
+		t.Errorf("TestResourceUpdateFake: unexpected last applied config, got: %s, want: %s.", getLastAppliedConfig(u), fakeConfigMapModified)
+	}
+	if state.ID != string(u.GetUID()) {
+		t.Errorf("TestResourceUpdateFake: expected in place update to keep the ID, got: %s, want: %s.", state.ID, u.GetUID())
+	}
+
+	c.failOn("patch", "configmaps", k8serrors.NewConflict(fakeConfigMapGVR.GroupResource(), "test", nil))
+
+	_, err = kustomizationResource().Apply(state, fakeManifestDiff(fakeConfigMapModified, fakeConfigMapInitial), c.config())
+	if err == nil || !strings.Contains(err.Error(), "ResourceUpdate: patching") {
+		t.Errorf("TestResourceUpdateFake: expected patch error, got: %v.", err)
+	}
+}
+
+func TestResourceDeleteFake(t *testing.T) {
+	c := newFakeCluster(t, fakeObject(t, fakeNamespaceManifest))
+	state := createFake(t, c, fakeConfigMapInitial)
+
+	d := kustomizationResource().Data(state)
+	err := kustomizationResourceDelete(d, c.config())
+	if err != nil {
+		t.Fatalf("TestResourceDeleteFake: %s", err)
+	}
+	if d.Id() != "" {
+		t.Errorf("TestResourceDeleteFake: expected empty ID, got: %s.", d.Id())
+	}
+
+	exists, err := kustomizationResourceExists(d, c.config())
+	if err != nil {
+		t.Fatalf("TestResourceDeleteFake: %s", err)
+	}
+	if exists {
+		t.Errorf("TestResourceDeleteFake: expected resource to be deleted")
+	}
+}
+
+func TestResourceDeleteNotFoundFake(t *testing.T) {
+	c := newFakeCluster(t, fakeObject(t, fakeNamespaceManifest))
+	state := createFake(t, c, fakeConfigMapInitial)
+
+	c.failOn("delete", "configmaps", k8serrors.NewNotFound(fakeConfigMapGVR.GroupResource(), "test"))
+
+	d := kustomizationResource().Data(state)
+	err := kustomizationResourceDelete(d, c.config())
+	if err != nil {
+		t.Fatalf("TestResourceDeleteNotFoundFake: %s", err)
+	}
+	if d.Id() != "" {
+		t.Errorf("TestResourceDeleteNotFoundFake: expected empty ID, got: %s.", d.Id())
+	}
+
+	c = newFakeCluster(t, fakeObject(t, fakeNamespaceManifest))
+	state = createFake(t, c, fakeConfigMapInitial)
+
+	c.failOn("delete", "configmaps", k8serrors.NewForbidden(fakeConfigMapGVR.GroupResource(), "test", nil))
+
+	d = kustomizationResource().Data(state)
+	err = kustomizationResourceDelete(d, c.config())
+	if err == nil || !strings.Contains(err.Error(), "ResourceDelete: deleting") {
+		t.Errorf("TestResourceDeleteNotFoundFake: expected delete error, got: %v.", err)
+	}
+	if d.Id() != state.ID {
+		t.Errorf("TestResourceDeleteNotFoundFake: expected ID to be kept on error, got: %s, want: %s.", d.Id(), state.ID)
+	}
+}
+
+// replaceFake deletes and recreates the object of manifest outside
+// of the provider, like kubectl would, with a new UID
+func replaceFake(t *testing.T, c *fakeCluster, gvr k8sschema.GroupVersionResource, manifest string, uid string) {
+	u := fakeObject(t, manifest)
+	u.SetUID(k8stypes.UID(uid))
+	setLastAppliedConfig(u, manifest)
+
+	err := c.tracker.Delete(gvr, u.GetNamespace(), u.GetName())
+	if err == nil {
+		err = c.tracker.Create(gvr, u, u.GetNamespace())
+	}
+	if err != nil {
+		t.Fatalf("replaceFake: %s", err)
+	}
+}
+
+func TestResourceReadUIDChangeFake(t *testing.T) {
+	const newUID = "11111111-1111-1111-1111-111111111111"
+
+	cases := []struct {
+		name               string
+		replaceOnUIDChange bool
+		wantID             string
+		requiresNew        bool
+	}{
+		{"accept", false, newUID, false},
+		{"replace", true, "", true},
+	}
+
+	for _, tc := range cases {
+		c := newFakeCluster(t, fakeObject(t, fakeNamespaceManifest))
+
+		d := schema.TestResourceDataRaw(t, kustomizationResource().Schema, map[string]interface{}{
+			"manifest":              fakeConfigMapInitial,
+			"replace_on_uid_change": tc.replaceOnUIDChange,
+		})
+		err := kustomizationResourceCreate(d, c.config())
+		if err != nil {
+			t.Fatalf("TestResourceReadUIDChangeFake: %s: %s", tc.name, err)
+		}
+		oldID := d.Id()
+		if d.Get("uid") != oldID {
+			t.Errorf("TestResourceReadUIDChangeFake: %s: expected uid to equal the ID after create, got: %s, want: %s.", tc.name, d.Get("uid"), oldID)
+		}
+
+		replaceFake(t, c, fakeConfigMapGVR, fakeConfigMapInitial, newUID)
+
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		err = kustomizationResourceRead(d, c.config())
+		log.SetOutput(os.Stderr)
+		if err != nil {
+			t.Fatalf("TestResourceReadUIDChangeFake: %s: %s", tc.name, err)
+		}
+
+		wantLog := fmt.Sprintf(`[WARN] kustomization_resource: operation="read" resid="~G_v1_ConfigMap|test-fake|test" event="uid_changed" old_uid="%s" new_uid="%s" replace=%t`, oldID, newUID, tc.replaceOnUIDChange)
+		if !strings.Contains(buf.String(), wantLog) {
+			t.Errorf("TestResourceReadUIDChangeFake: %s: expected log containing '%s', got:\n%s", tc.name, wantLog, buf.String())
+		}
+
+		wantID := tc.wantID
+		if wantID == "" {
+			wantID = oldID
+		}
+		if d.Id() != wantID {
+			t.Errorf("TestResourceReadUIDChangeFake: %s: unexpected ID, got: %s, want: %s.", tc.name, d.Id(), wantID)
+		}
+		if d.Get("uid") != newUID {
+			t.Errorf("TestResourceReadUIDChangeFake: %s: expected uid to be refreshed, got: %s, want: %s.", tc.name, d.Get("uid"), newUID)
+		}
+
+		config := terraform.NewResourceConfigRaw(map[string]interface{}{
+			"manifest":              fakeConfigMapInitial,
+			"replace_on_uid_change": tc.replaceOnUIDChange,
+		})
+		diff, err := kustomizationResource().Diff(d.State(), config, c.config())
+		if err != nil {
+			t.Fatalf("TestResourceReadUIDChangeFake: %s: %s", tc.name, err)
+		}
+		if diff.RequiresNew() != tc.requiresNew {
+			t.Errorf("TestResourceReadUIDChangeFake: %s: unexpected RequiresNew, got: %t, want: %t.", tc.name, diff.RequiresNew(), tc.requiresNew)
+		}
+	}
+}
+
+func TestResourceOmitSecretDataFake(t *testing.T) {
+	secretGVR := k8sschema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	manifest := `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"test","namespace":"test-fake"},"data":{"password":"c2VjcmV0"}}`
+
+	c := newFakeCluster(t, fakeObject(t, fakeNamespaceManifest))
+
+	d := schema.TestResourceDataRaw(t, kustomizationResource().Schema, map[string]interface{}{
+		"manifest":                         manifest,
+		"omit_secret_data_in_last_applied": false,
+	})
+	err := kustomizationResourceCreate(d, c.config())
+	if err != nil {
+		t.Fatalf("TestResourceOmitSecretDataFake: %s", err)
+	}
+	state := d.State()
+
+	for _, omit := range []bool{true, false} {
+		config := terraform.NewResourceConfigRaw(map[string]interface{}{
+			"manifest":                         manifest,
+			"omit_secret_data_in_last_applied": omit,
+		})
+		diff, err := kustomizationResource().Diff(state, config, c.config())
+		if err != nil {
+			t.Fatalf("TestResourceOmitSecretDataFake: omit %t: %s", omit, err)
+		}
+		if !diff.RequiresNew() {
+			t.Errorf("TestResourceOmitSecretDataFake: omit %t: expected changing the flag to replace the object", omit)
+		}
+
+		state, err = kustomizationResource().Apply(state, diff, c.config())
+		if err != nil {
+			t.Fatalf("TestResourceOmitSecretDataFake: omit %t: %s", omit, err)
+		}
+
+		u := c.get(secretGVR, "test-fake", "test")
+		lac := getLastAppliedConfig(u)
+		if omit && strings.Contains(lac, "c2VjcmV0") {
+			t.Errorf("TestResourceOmitSecretDataFake: omit %t: expected redacted annotation, got: %s.", omit, lac)
+		}
+		if !omit && lac != manifest {
+			t.Errorf("TestResourceOmitSecretDataFake: omit %t: expected the real Secret data in the annotation, got: %s, want: %s.", omit, lac, manifest)
+		}
+		if u.Object["data"].(map[string]interface{})["password"] != "c2VjcmV0" {
+			t.Errorf("TestResourceOmitSecretDataFake: omit %t: expected the Secret data to be kept, got: %v.", omit, u.Object["data"])
+		}
+	}
+}
+
+func TestResourceOmitSecretDataOtherKindsFake(t *testing.T) {
+	c := newFakeCluster(t, fakeObject(t, fakeNamespaceManifest))
+
+	d := schema.TestResourceDataRaw(t, kustomizationResource().Schema, map[string]interface{}{
+		"manifest":                         fakeConfigMapInitial,
+		"omit_secret_data_in_last_applied": false,
+	})
+	err := kustomizationResourceCreate(d, c.config())
+	if err != nil {
+		t.Fatalf("TestResourceOmitSecretDataOtherKindsFake: %s", err)
+	}
+	state := d.State()
+	uid := c.get(fakeConfigMapGVR, "test-fake", "test").GetUID()
+
+	for _, omit := range []bool{true, false} {
+		config := terraform.NewResourceConfigRaw(map[string]interface{}{
+			"manifest":                         fakeConfigMapInitial,
+			"omit_secret_data_in_last_applied": omit,
+		})
+		diff, err := kustomizationResource().Diff(state, config, c.config())
+		if err != nil {
+			t.Fatalf("TestResourceOmitSecretDataOtherKindsFake: omit %t: %s", omit, err)
+		}
+		if diff.RequiresNew() {
+			t.Errorf("TestResourceOmitSecretDataOtherKindsFake: omit %t: expected changing the flag to not replace a ConfigMap", omit)
+		}
+
+		state, err = kustomizationResource().Apply(state, diff, c.config())
+		if err != nil {
+			t.Fatalf("TestResourceOmitSecretDataOtherKindsFake: omit %t: %s", omit, err)
+		}
+
+		got := c.get(fakeConfigMapGVR, "test-fake", "test").GetUID()
+		if got != uid {
+			t.Errorf("TestResourceOmitSecretDataOtherKindsFake: omit %t: expected the object to be kept, got UID: %s, want: %s.", omit, got, uid)
+		}
+		if state.Attributes["omit_secret_data_in_last_applied"] != fmt.Sprint(omit) {
+			t.Errorf("TestResourceOmitSecretDataOtherKindsFake: omit %t: expected the flag to be updated, got: %s.", omit, state.Attributes["omit_secret_data_in_last_applied"])
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/kustomize/api/resid"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+)
+
+// importIDFormats is included in errors about invalid import IDs
+const importIDFormats = `expected one of:
+  - kustomize resource ID, e.g. 'apps_v1_Deployment|example|test' or '~G_v1_Namespace|~X|example'
+  - kind/name, e.g. 'namespace/example', namespaced kinds use the default namespace
+  - namespace/kind/name, e.g. 'example/deployment/test'
+  - group/version/kind/namespace/name, e.g. 'apps/v1/Deployment/example/test',
+    with an empty group for the core group, an empty version for the
+    preferred version and an empty namespace for cluster scoped kinds
+  - UID, e.g. '4bd5b2bd-8c3c-4d1b-9a4e-1b0d2c1f7e3a'`
+
+// uidPattern matches the UIDs the API server assigns
+var uidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// importTarget is the resource an import ID resolved to
+type importTarget struct {
+	gvr       k8sschema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+// getImportObject returns the object the import ID refers to
+func getImportObject(id string, m interface{}) (*k8sunstructured.Unstructured, error) {
+	client := m.(*Config).Client
+
+	// the resid is unknown until the object is found
+	logger := resourceLogger{operation: "import", resid: id}
+
+	if uidPattern.MatchString(id) {
+		return findObjectByUID(id, logger, m)
+	}
+
+	target, err := parseImportID(id, m)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := client.
+		Resource(target.gvr).
+		Namespace(target.namespace).
+		Get(context.TODO(), target.name, k8smetav1.GetOptions{})
+	logger.apiCall("get", target.gvr, target.namespace, target.name, start, err)
+	if err != nil {
+		return nil, fmt.Errorf("reading '%s' failed: %s", target.gvr, err)
+	}
+
+	return resp, nil
+}
+
+// parseImportID resolves all import ID formats, except UIDs,
+// to the GroupVersionResource, namespace and name to get
+func parseImportID(id string, m interface{}) (t importTarget, err error) {
+	cgvk := m.(*Config).CachedGroupVersionKind
+
+	if strings.Contains(id, "|") {
+		if len(strings.Split(id, "|")) != 3 {
+			return t, fmt.Errorf("invalid ID '%s', %s", id, importIDFormats)
+		}
+
+		rid := resid.FromString(id)
+		gvk := k8sschema.GroupVersionKind{
+			Group:   rid.Gvk.Group,
+			Version: rid.Gvk.Version,
+			Kind:    rid.Gvk.Kind,
+		}
+		t.gvr, err = cgvk.getGVR(gvk, false)
+		if err != nil {
+			return t, err
+		}
+		t.namespace = rid.Namespace
+		t.name = rid.Name
+
+		return t, nil
+	}
+
+	// the discovery cache may predate CRDs created since,
+	// imports are rare enough to always refresh it
+	rm, err := cgvk.getRESTMapper(true)
+	if err != nil {
+		return t, err
+	}
+
+	var mapping *meta.RESTMapping
+	parts := strings.Split(id, "/")
+	switch len(parts) {
+	case 2:
+		mapping, err = mappingForKind(rm, cgvk.dc, parts[0])
+		t.name = parts[1]
+	case 3:
+		mapping, err = mappingForKind(rm, cgvk.dc, parts[1])
+		t.namespace = parts[0]
+		t.name = parts[2]
+	case 5:
+		gk := k8sschema.GroupKind{Group: parts[0], Kind: parts[2]}
+		var versions []string
+		if parts[1] != "" {
+			versions = append(versions, parts[1])
+		}
+		mapping, err = rm.RESTMapping(gk, versions...)
+		if err != nil {
+			err = fmt.Errorf("mapping GroupKind failed for '%s': %s", gk, err)
+		}
+		t.namespace = parts[3]
+		t.name = parts[4]
+	default:
+		return t, fmt.Errorf("invalid ID '%s', %s", id, importIDFormats)
+	}
+	if err != nil {
+		return t, err
+	}
+
+	if t.name == "" {
+		return t, fmt.Errorf("invalid ID '%s', name is empty, %s", id, importIDFormats)
+	}
+
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	if namespaced && t.namespace == "" {
+		t.namespace = "default"
+	}
+	if !namespaced && t.namespace != "" {
+		return t, fmt.Errorf("invalid ID '%s', kind '%s' is cluster scoped, but namespace '%s' is set", id, mapping.GroupVersionKind.Kind, t.namespace)
+	}
+
+	t.gvr = mapping.Resource
+
+	return t, nil
+}
+
+// mappingForKind resolves kind like kubectl does, it can be the
+// kind, the singular or plural resource name or a short name,
+// optionally with the group, e.g. Deployment, deploy or
+// deployments.apps. It returns the preferred version's mapping.
+func mappingForKind(rm meta.RESTMapper, dc discovery.DiscoveryInterface, kind string) (*meta.RESTMapping, error) {
+	if kind == "" {
+		return nil, fmt.Errorf("kind is empty, %s", importIDFormats)
+	}
+
+	expander := restmapper.NewShortcutExpander(rm, dc)
+	gr := k8sschema.ParseGroupResource(kind)
+
+	gvk, err := expander.KindFor(gr.WithVersion(""))
+	if err != nil {
+		return nil, fmt.Errorf("mapping kind failed for '%s': %s", kind, err)
+	}
+
+	mapping, err := rm.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("mapping GroupKind failed for '%s': %s", gvk.GroupKind(), err)
+	}
+
+	return mapping, nil
+}
+
+// findObjectByUID lists all listable resources the cluster serves,
+// in their preferred version, and returns the object with the UID.
+// There is no field selector for metadata.uid, so this scans
+// every object the credentials can list.
+func findObjectByUID(uid string, logger resourceLogger, m interface{}) (*k8sunstructured.Unstructured, error) {
+	client := m.(*Config).Client
+	cgvk := m.(*Config).CachedGroupVersionKind
+
+	lists, err := discovery.ServerPreferredResources(cgvk.dc)
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("discovering preferred resources failed: %s", err)
+	}
+	lists = discovery.FilteredBy(discovery.SupportsAllVerbs{Verbs: []string{"list"}}, lists)
+
+	for _, l := range lists {
+		gv, err := k8sschema.ParseGroupVersion(l.GroupVersion)
+		if err != nil {
+			continue
+		}
+
+		for _, ar := range l.APIResources {
+			gvr := gv.WithResource(ar.Name)
+			start := time.Now()
+			resp, err := client.
+				Resource(gvr).
+				List(context.TODO(), k8smetav1.ListOptions{})
+			logger.apiCall("list", gvr, "", "", start, err)
+			if err != nil {
+				// skip resources the credentials can't list
+				continue
+			}
+
+			for i := range resp.Items {
+				if string(resp.Items[i].GetUID()) == uid {
+					return &resp.Items[i], nil
+				}
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no object with UID '%s' found", uid)
+}
@@ -0,0 +1,174 @@
+package kustomize
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+var importTestObjects = []struct {
+	gvr      k8sschema.GroupVersionResource
+	manifest string
+}{
+	{
+		k8sschema.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+		`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"test-import"}}`,
+	},
+	{
+		k8sschema.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+		`{"apiVersion":"v1","kind":"Namespace","metadata":{"name":"default"}}`,
+	},
+	{
+		k8sschema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		`{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"test","namespace":"test-import"}}`,
+	},
+	{
+		k8sschema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+		`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test","namespace":"default"}}`,
+	},
+	{
+		k8sschema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"},
+		`{"apiVersion":"apiextensions.k8s.io/v1","kind":"CustomResourceDefinition","metadata":{"name":"tests.example.com"}}`,
+	},
+}
+
+// startImportTestAPIServer returns a Config for a fake API
+// server with the importTestObjects and their UIDs by name
+func startImportTestAPIServer(t *testing.T) (*httptest.Server, *Config, map[string]string) {
+	ts := httptest.NewServer(newFakeAPIServer())
+
+	client, dc, err := newKubernetesClients(&rest.Config{Host: ts.URL, QPS: 120, Burst: 240})
+	if err != nil {
+		ts.Close()
+		t.Fatalf("startImportTestAPIServer: %s", err)
+	}
+
+	uids := make(map[string]string)
+	for _, o := range importTestObjects {
+		u := fakeObject(t, o.manifest)
+		resp, err := client.
+			Resource(o.gvr).
+			Namespace(u.GetNamespace()).
+			Create(context.TODO(), u, k8smetav1.CreateOptions{})
+		if err != nil {
+			ts.Close()
+			t.Fatalf("startImportTestAPIServer: %s", err)
+		}
+		uids[resp.GetKind()+"/"+resp.GetNamespace()+"/"+resp.GetName()] = string(resp.GetUID())
+	}
+
+	m := &Config{
+		Client:                 client,
+		Discovery:              dc,
+		CachedGroupVersionKind: newCachedGroupVersionKind(dc),
+	}
+
+	return ts, m, uids
+}
+
+func TestResourceImportIDFormats(t *testing.T) {
+	ts, m, uids := startImportTestAPIServer(t)
+	defer ts.Close()
+
+	cases := []struct {
+		id   string
+		want string
+	}{
+		{"apps_v1_Deployment|test-import|test", "Deployment/test-import/test"},
+		{"~G_v1_Namespace|~X|test-import", "Namespace//test-import"},
+		{"namespace/test-import", "Namespace//test-import"},
+		{"ns/test-import", "Namespace//test-import"},
+		{"ConfigMap/test", "ConfigMap/default/test"},
+		{"test-import/deployment/test", "Deployment/test-import/test"},
+		{"test-import/deploy/test", "Deployment/test-import/test"},
+		{"test-import/deployments.apps/test", "Deployment/test-import/test"},
+		{"apps/v1/Deployment/test-import/test", "Deployment/test-import/test"},
+		{"apps//Deployment/test-import/test", "Deployment/test-import/test"},
+		{"/v1/Namespace//test-import", "Namespace//test-import"},
+		{"apiextensions.k8s.io//CustomResourceDefinition//tests.example.com", "CustomResourceDefinition//tests.example.com"},
+		{uids["Deployment/test-import/test"], "Deployment/test-import/test"},
+		{uids["Namespace//test-import"], "Namespace//test-import"},
+	}
+
+	for _, tc := range cases {
+		u, err := getImportObject(tc.id, m)
+		if err != nil {
+			t.Errorf("TestResourceImportIDFormats: %s: %s", tc.id, err)
+			continue
+		}
+
+		got := u.GetKind() + "/" + u.GetNamespace() + "/" + u.GetName()
+		if got != tc.want {
+			t.Errorf("TestResourceImportIDFormats: %s: got: %s, want: %s.", tc.id, got, tc.want)
+		}
+		if string(u.GetUID()) != uids[tc.want] {
+			t.Errorf("TestResourceImportIDFormats: %s: unexpected UID, got: %s, want: %s.", tc.id, u.GetUID(), uids[tc.want])
+		}
+	}
+}
+
+func TestResourceImportIDErrors(t *testing.T) {
+	ts, m, _ := startImportTestAPIServer(t)
+	defer ts.Close()
+
+	cases := []struct {
+		id      string
+		wantErr string
+	}{
+		{"test", "expected one of:"},
+		{"a/b/c/d", "expected one of:"},
+		{"a|b", "expected one of:"},
+		{"namespace/", "name is empty"},
+		{"/test", "kind is empty"},
+		{"test-import/namespace/test", "is cluster scoped"},
+		{"test-import/doesnotexist/test", "mapping kind failed for 'doesnotexist'"},
+		{"apps/v2/Deployment/test-import/test", "mapping GroupKind failed"},
+		{"test-import/deployment/missing", "not found"},
+		{"00000000-0000-0000-0000-000000000000", "no object with UID"},
+	}
+
+	for _, tc := range cases {
+		_, err := getImportObject(tc.id, m)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("TestResourceImportIDErrors: %s: expected error containing '%s', got: %v.", tc.id, tc.wantErr, err)
+		}
+	}
+}
+
+func TestResourceImportID(t *testing.T) {
+	ts, m, uids := startImportTestAPIServer(t)
+	defer ts.Close()
+
+	r := kustomizationResource()
+	d := r.Data(nil)
+	d.SetId("test-import/deploy/test")
+
+	results, err := r.Importer.State(d, m)
+	if err != nil {
+		t.Fatalf("TestResourceImportID: %s", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("TestResourceImportID: expected one result, got: %d.", len(results))
+	}
+	if results[0].Id() != uids["Deployment/test-import/test"] {
+		t.Errorf("TestResourceImportID: expected ID to be the UID, got: %s, want: %s.", results[0].Id(), uids["Deployment/test-import/test"])
+	}
+
+	// created without the last applied configuration annotation
+	wantManifest := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"test","namespace":"test-import"}}`
+	if results[0].Get("manifest") != wantManifest {
+		t.Errorf("TestResourceImportID: expected manifest from the live object, got: %s, want: %s.", results[0].Get("manifest"), wantManifest)
+	}
+
+	d = r.Data(nil)
+	d.SetId("test")
+	_, err = r.Importer.State(d, m)
+	if err == nil || !strings.HasPrefix(err.Error(), "ResourceImport: invalid ID 'test'") {
+		t.Errorf("TestResourceImportID: expected invalid ID error, got: %v.", err)
+	}
+}
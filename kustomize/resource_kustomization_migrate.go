@@ -0,0 +1,83 @@
+package kustomize
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// kustomizationResourceV0 is the schema of kustomization_resource
+// state before schema versioning, only used to decode old state
+func kustomizationResourceV0() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"manifest": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"omit_secret_data_in_last_applied": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+		},
+	}
+}
+
+// kustomizationResourceStateUpgradeV0 adds the computed resid, gvk,
+// namespace and name attributes. Imports of objects without the last
+// applied configuration annotation stored an empty manifest before,
+// their attributes are left for the next refresh to set.
+//
+// State written before omit_secret_data_in_last_applied existed has
+// neither the attribute nor redacted Secret manifests.
+func kustomizationResourceStateUpgradeV0(rawState map[string]interface{}, m interface{}) (map[string]interface{}, error) {
+	if _, ok := rawState["omit_secret_data_in_last_applied"]; !ok {
+		rawState["omit_secret_data_in_last_applied"] = false
+	}
+
+	manifest, _ := rawState["manifest"].(string)
+	if manifest == "" {
+		return rawState, nil
+	}
+
+	manifest, err := redactSecretManifest(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("StateUpgradeV0: %s", err)
+	}
+	rawState["manifest"] = manifest
+
+	u, err := parseJSON(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("StateUpgradeV0: %s", err)
+	}
+
+	for k, v := range computedAttributesFor(u) {
+		rawState[k] = v
+	}
+
+	return rawState, nil
+}
+
+// kustomizationResourceV1 is the schema of kustomization_resource
+// state with the computed attributes of the manifest
+func kustomizationResourceV1() *schema.Resource {
+	r := kustomizationResourceV0()
+	for _, k := range []string{"resid", "gvk", "namespace", "name"} {
+		r.Schema[k] = &schema.Schema{
+			Type:     schema.TypeString,
+			Computed: true,
+		}
+	}
+
+	return r
+}
+
+// kustomizationResourceStateUpgradeV1 adds the uid attribute. The
+// ID has always been the UID of the object, when it was last read.
+func kustomizationResourceStateUpgradeV1(rawState map[string]interface{}, m interface{}) (map[string]interface{}, error) {
+	rawState["uid"] = rawState["id"]
+	rawState["replace_on_uid_change"] = false
+
+	return rawState, nil
+}
@@ -0,0 +1,132 @@
+package kustomize
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// stateFixturesDir has recorded kustomization_resource state
+// attributes, in a directory per schema version. Upgrading a
+// fixture from any previous version has to result in the fixture
+// of the same name in the current version's directory.
+const stateFixturesDir = "testdata/state"
+
+// upgradeState runs all state upgraders of r, starting at version
+func upgradeState(r *schema.Resource, version int, rawState map[string]interface{}) (map[string]interface{}, error) {
+	for _, u := range r.StateUpgraders {
+		if u.Version < version {
+			continue
+		}
+
+		var err error
+		rawState, err = u.Upgrade(rawState, &Config{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rawState, nil
+}
+
+func TestResourceStateUpgrade(t *testing.T) {
+	r := kustomizationResource()
+	currentDir := filepath.Join(stateFixturesDir, fmt.Sprintf("v%d", r.SchemaVersion))
+
+	for _, upgrader := range r.StateUpgraders {
+		fixtures, err := filepath.Glob(filepath.Join(stateFixturesDir, fmt.Sprintf("v%d", upgrader.Version), "*.json"))
+		if err != nil || len(fixtures) == 0 {
+			t.Fatalf("TestResourceStateUpgrade: no v%d fixtures found: %v", upgrader.Version, err)
+		}
+
+		for _, fixture := range fixtures {
+			name := fmt.Sprintf("v%d/%s", upgrader.Version, filepath.Base(fixture))
+
+			data, err := ioutil.ReadFile(fixture)
+			if err != nil {
+				t.Fatalf("TestResourceStateUpgrade: %s: %s", name, err)
+			}
+
+			// the fixture has to be valid state of the old schema
+			_, err = ctyjson.Unmarshal(data, upgrader.Type)
+			if err != nil {
+				t.Errorf("TestResourceStateUpgrade: %s: invalid state: %s", name, err)
+				continue
+			}
+
+			var rawState map[string]interface{}
+			err = json.Unmarshal(data, &rawState)
+			if err != nil {
+				t.Fatalf("TestResourceStateUpgrade: %s: %s", name, err)
+			}
+
+			got, err := upgradeState(r, upgrader.Version, rawState)
+			if err != nil {
+				t.Errorf("TestResourceStateUpgrade: %s: %s", name, err)
+				continue
+			}
+
+			// the result has to be valid state of the current schema
+			gotJSON, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("TestResourceStateUpgrade: %s: %s", name, err)
+			}
+			_, err = ctyjson.Unmarshal(gotJSON, r.CoreConfigSchema().ImpliedType())
+			if err != nil {
+				t.Errorf("TestResourceStateUpgrade: %s: invalid v%d state: %s", name, r.SchemaVersion, err)
+			}
+
+			wantData, err := ioutil.ReadFile(filepath.Join(currentDir, filepath.Base(fixture)))
+			if err != nil {
+				t.Fatalf("TestResourceStateUpgrade: %s: %s", name, err)
+			}
+			var want map[string]interface{}
+			err = json.Unmarshal(wantData, &want)
+			if err != nil {
+				t.Fatalf("TestResourceStateUpgrade: %s: %s", name, err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("TestResourceStateUpgrade: %s: got: %s, want: %s.", name, gotJSON, wantData)
+			}
+		}
+	}
+}
+
+func TestResourceStateUpgradeInvalidManifest(t *testing.T) {
+	_, err := kustomizationResourceStateUpgradeV0(map[string]interface{}{
+		"id":       "4bd5b2bd-8c3c-4d1b-9a4e-1b0d2c1f7e3a",
+		"manifest": "{",
+	}, &Config{})
+	if err == nil {
+		t.Errorf("TestResourceStateUpgradeInvalidManifest: expected error for invalid manifest")
+	}
+}
+
+func TestResourceSchemaVersion(t *testing.T) {
+	r := kustomizationResource()
+
+	// every version before the current needs an upgrader
+	for v := 0; v < r.SchemaVersion; v++ {
+		found := false
+		for _, u := range r.StateUpgraders {
+			if u.Version == v {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("TestResourceSchemaVersion: missing state upgrader for version %d", v)
+		}
+	}
+
+	_, err := ioutil.ReadDir(filepath.Join(stateFixturesDir, fmt.Sprintf("v%d", r.SchemaVersion)))
+	if err != nil {
+		t.Errorf("TestResourceSchemaVersion: expected state fixtures for the current version: %s", err)
+	}
+}
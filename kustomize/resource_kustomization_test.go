@@ -32,6 +32,12 @@ func TestAccResourceKustomization_basic(t *testing.T) {
 					resource.TestCheckResourceAttrSet("kustomization_resource.ns", "id"),
 					resource.TestCheckResourceAttrSet("kustomization_resource.svc", "id"),
 					resource.TestCheckResourceAttrSet("kustomization_resource.dep1", "id"),
+					resource.TestCheckResourceAttr("kustomization_resource.ns", "resid", "~G_v1_Namespace|~X|test-basic"),
+					resource.TestCheckResourceAttr("kustomization_resource.ns", "namespace", ""),
+					resource.TestCheckResourceAttr("kustomization_resource.dep1", "resid", "apps_v1_Deployment|test-basic|test"),
+					resource.TestCheckResourceAttr("kustomization_resource.dep1", "gvk", "apps_v1_Deployment"),
+					resource.TestCheckResourceAttr("kustomization_resource.dep1", "namespace", "test-basic"),
+					resource.TestCheckResourceAttr("kustomization_resource.dep1", "name", "test"),
 				),
 			},
 			//
@@ -1,7 +1,17 @@
 package kustomize
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+
+	"sigs.k8s.io/kustomize/api/resid"
 	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/types"
 )
 
 func flattenKustomizationIDs(rm resmap.ResMap) (ids []string) {
@@ -12,16 +22,213 @@ func flattenKustomizationIDs(rm resmap.ResMap) (ids []string) {
 	return ids
 }
 
-func flattenKustomizationResources(rm resmap.ResMap) (res map[string]string, err error) {
+func flattenKustomizationResources(rm resmap.ResMap) (res map[string]string, sensitiveRes map[string]string, err error) {
 	res = make(map[string]string)
+	sensitiveRes = make(map[string]string)
 	for _, r := range rm.Resources() {
 		id := r.CurId().String()
 		json, err := r.MarshalJSON()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+
+		if isSensitiveGroupKind(r.GetGvk().Group, r.GetGvk().Kind) {
+			sensitiveRes[id] = string(json)
+			continue
 		}
 		res[id] = string(json)
 	}
 
-	return res, nil
+	return res, sensitiveRes, nil
+}
+
+func flattenKustomizationHashes(rm resmap.ResMap) (map[string]string, error) {
+	hashes := make(map[string]string)
+	for _, r := range rm.Resources() {
+		data, err := r.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		hash, err := canonicalJSONHash(data)
+		if err != nil {
+			return nil, fmt.Errorf("hashing %s failed: %s", r.CurId(), err)
+		}
+		hashes[r.CurId().String()] = hash
+	}
+
+	return hashes, nil
+}
+
+// canonicalJSONHash returns the sha256 of the canonical form of a
+// JSON document, i.e. with sorted keys and without whitespace, so
+// it only changes if the data does
+func canonicalJSONHash(data []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	err := dec.Decode(&v)
+	if err != nil {
+		return "", err
+	}
+
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.Sum256(canonical)
+	return hex.EncodeToString(h[:]), nil
+}
+
+func expandStringList(l []interface{}) (s []string) {
+	for _, v := range l {
+		s = append(s, v.(string))
+	}
+
+	return s
+}
+
+func expandStringMap(m map[string]interface{}) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	s := make(map[string]string)
+	for k, v := range m {
+		s[k] = v.(string)
+	}
+
+	return s
+}
+
+// expandKustomizationOverlay builds a kustomization from the
+// attributes and blocks of the kustomization_overlay data source
+func expandKustomizationOverlay(d *schema.ResourceData) types.Kustomization {
+	k := types.Kustomization{
+		TypeMeta: types.TypeMeta{
+			APIVersion: types.KustomizationVersion,
+			Kind:       types.KustomizationKind,
+		},
+		Resources:         expandStringList(d.Get("resources").([]interface{})),
+		Namespace:         d.Get("namespace").(string),
+		NamePrefix:        d.Get("name_prefix").(string),
+		NameSuffix:        d.Get("name_suffix").(string),
+		CommonLabels:      expandStringMap(d.Get("common_labels").(map[string]interface{})),
+		CommonAnnotations: expandStringMap(d.Get("common_annotations").(map[string]interface{})),
+		GeneratorOptions:  expandGeneratorOptions(d.Get("generator_options").([]interface{})),
+	}
+
+	k.Images = expandImages(d.Get("images").([]interface{}))
+	k.Replicas = expandReplicas(d.Get("replicas").([]interface{}))
+
+	for _, v := range d.Get("patches").([]interface{}) {
+		p := v.(map[string]interface{})
+		k.Patches = append(k.Patches, types.Patch{
+			Path:   p["path"].(string),
+			Patch:  p["patch"].(string),
+			Target: expandPatchTarget(p["target"].([]interface{})),
+		})
+	}
+
+	for _, v := range d.Get("config_map_generator").([]interface{}) {
+		k.ConfigMapGenerator = append(k.ConfigMapGenerator, types.ConfigMapArgs{
+			GeneratorArgs: expandGeneratorArgs(v.(map[string]interface{})),
+		})
+	}
+
+	for _, v := range d.Get("secret_generator").([]interface{}) {
+		s := v.(map[string]interface{})
+		k.SecretGenerator = append(k.SecretGenerator, types.SecretArgs{
+			GeneratorArgs: expandGeneratorArgs(s),
+			Type:          s["type"].(string),
+		})
+	}
+
+	return k
+}
+
+// expandKustomizationOverrides returns a kustomization with the
+// overrides of the kustomization data source
+func expandKustomizationOverrides(d *schema.ResourceData) types.Kustomization {
+	return types.Kustomization{
+		Namespace:         d.Get("namespace").(string),
+		NamePrefix:        d.Get("name_prefix").(string),
+		NameSuffix:        d.Get("name_suffix").(string),
+		CommonLabels:      expandStringMap(d.Get("common_labels").(map[string]interface{})),
+		CommonAnnotations: expandStringMap(d.Get("common_annotations").(map[string]interface{})),
+	}
+}
+
+func expandImages(l []interface{}) (images []types.Image) {
+	for _, v := range l {
+		i := v.(map[string]interface{})
+		images = append(images, types.Image{
+			Name:    i["name"].(string),
+			NewName: i["new_name"].(string),
+			NewTag:  i["new_tag"].(string),
+			Digest:  i["digest"].(string),
+		})
+	}
+
+	return images
+}
+
+func expandReplicas(l []interface{}) (replicas []types.Replica) {
+	for _, v := range l {
+		r := v.(map[string]interface{})
+		replicas = append(replicas, types.Replica{
+			Name:  r["name"].(string),
+			Count: int64(r["count"].(int)),
+		})
+	}
+
+	return replicas
+}
+
+func expandPatchTarget(l []interface{}) *types.Selector {
+	if len(l) == 0 || l[0] == nil {
+		return nil
+	}
+
+	t := l[0].(map[string]interface{})
+	return &types.Selector{
+		Gvk: resid.Gvk{
+			Group:   t["group"].(string),
+			Version: t["version"].(string),
+			Kind:    t["kind"].(string),
+		},
+		Name:               t["name"].(string),
+		Namespace:          t["namespace"].(string),
+		LabelSelector:      t["label_selector"].(string),
+		AnnotationSelector: t["annotation_selector"].(string),
+	}
+}
+
+func expandGeneratorArgs(g map[string]interface{}) types.GeneratorArgs {
+	return types.GeneratorArgs{
+		Name:      g["name"].(string),
+		Namespace: g["namespace"].(string),
+		Behavior:  g["behavior"].(string),
+		KvPairSources: types.KvPairSources{
+			LiteralSources: expandStringList(g["literals"].([]interface{})),
+			FileSources:    expandStringList(g["files"].([]interface{})),
+			EnvSources:     expandStringList(g["envs"].([]interface{})),
+		},
+		Options: expandGeneratorOptions(g["options"].([]interface{})),
+	}
+}
+
+func expandGeneratorOptions(l []interface{}) *types.GeneratorOptions {
+	if len(l) == 0 || l[0] == nil {
+		return nil
+	}
+
+	o := l[0].(map[string]interface{})
+	return &types.GeneratorOptions{
+		Labels:                expandStringMap(o["labels"].(map[string]interface{})),
+		Annotations:           expandStringMap(o["annotations"].(map[string]interface{})),
+		DisableNameSuffixHash: o["disable_name_suffix_hash"].(bool),
+	}
 }
@@ -0,0 +1,95 @@
+package kustomize
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+func TestCanonicalJSONHash(t *testing.T) {
+	a, err := canonicalJSONHash([]byte(`{"kind": "ConfigMap", "data": {"a": "1", "b": "2"}, "replicas": 10000000000000001}`))
+	if err != nil {
+		t.Fatalf("TestCanonicalJSONHash: %s", err)
+	}
+
+	b, err := canonicalJSONHash([]byte(`{"replicas":10000000000000001,"data":{"b":"2","a":"1"},"kind":"ConfigMap"}`))
+	if err != nil {
+		t.Fatalf("TestCanonicalJSONHash: %s", err)
+	}
+
+	if a != b {
+		t.Errorf("TestCanonicalJSONHash: hash depends on key order or whitespace, got: %s, want: %s.", b, a)
+	}
+
+	c, _ := canonicalJSONHash([]byte(`{"replicas":10000000000000002,"data":{"b":"2","a":"1"},"kind":"ConfigMap"}`))
+	if a == c {
+		t.Errorf("TestCanonicalJSONHash: expected different hash for different data, got: %s.", c)
+	}
+}
+
+func TestKustomizationHashesStable(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	fSys.WriteFile("/a/kustomization.yaml", []byte("resources:\n- resources.yaml\n"))
+	fSys.WriteFile("/a/resources.yaml", []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: one
+data:
+  a: "1"
+  b: "2"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: two
+data:
+  c: "3"
+`))
+	fSys.WriteFile("/b/kustomization.yaml", []byte("resources:\n- resources.yaml\n"))
+	fSys.WriteFile("/b/resources.yaml", []byte(`# reordered yaml flow style
+{"kind": "ConfigMap", "apiVersion": "v1", "data": {"c": "3"}, "metadata": {"name": "two"}}
+---
+{"data": {"b": "2", "a": "1"}, "metadata": {"name": "one"}, "kind": "ConfigMap", "apiVersion": "v1"}
+`))
+	fSys.WriteFile("/c/kustomization.yaml", []byte("resources:\n- resources.yaml\n"))
+	fSys.WriteFile("/c/resources.yaml", []byte(`# changed
+{"kind": "ConfigMap", "apiVersion": "v1", "data": {"c": "changed"}, "metadata": {"name": "two"}}
+---
+{"data": {"b": "2", "a": "1"}, "metadata": {"name": "one"}, "kind": "ConfigMap", "apiVersion": "v1"}
+`))
+
+	hashes := make(map[string]map[string]string)
+	ids := make(map[string]string)
+	for _, path := range []string{"/a", "/b", "/c"} {
+		rm, err := runKustomizeBuildWithFileSys(fSys, path)
+		if err != nil {
+			t.Fatalf("TestKustomizationHashesStable: %s", err)
+		}
+
+		hashes[path], err = flattenKustomizationHashes(rm)
+		if err != nil {
+			t.Fatalf("TestKustomizationHashesStable: %s", err)
+		}
+		ids[path], err = getIDFromResources(rm)
+		if err != nil {
+			t.Fatalf("TestKustomizationHashesStable: %s", err)
+		}
+	}
+
+	one := "~G_v1_ConfigMap|~X|one"
+	two := "~G_v1_ConfigMap|~X|two"
+
+	if ids["/a"] != ids["/b"] {
+		t.Errorf("TestKustomizationHashesStable: ID depends on order or serialization, got: %s, want: %s.", ids["/b"], ids["/a"])
+	}
+	if ids["/a"] == ids["/c"] {
+		t.Errorf("TestKustomizationHashesStable: expected ID to change with a resource")
+	}
+	if hashes["/a"][one] != hashes["/c"][one] {
+		t.Errorf("TestKustomizationHashesStable: hash of unchanged resource changed, got: %s, want: %s.", hashes["/c"][one], hashes["/a"][one])
+	}
+	if hashes["/a"][two] == hashes["/c"][two] {
+		t.Errorf("TestKustomizationHashesStable: expected hash of changed resource to change")
+	}
+}
@@ -2,13 +2,19 @@ package kustomize
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	k8scorev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
 
 	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
 	"k8s.io/apimachinery/pkg/util/mergepatch"
@@ -16,6 +22,169 @@ import (
 
 const lastAppliedConfig = k8scorev1.LastAppliedConfigAnnotation
 
+// redactedValuePrefix marks Secret values that have been replaced
+// by a hash of their content
+const redactedValuePrefix = "(sensitive value) sha256:"
+
+var secretDataFields = [...]string{
+	"data",
+	"stringData",
+}
+
+func isSensitiveGroupKind(group string, kind string) bool {
+	return group == "" && kind == "Secret"
+}
+
+// redactSecretManifest replaces the values of a Secret's data and
+// stringData fields with a hash of their content, so changes remain
+// detectable without exposing the payload. Manifests of all other
+// kinds are returned unchanged.
+func redactSecretManifest(srcJSON string) (string, error) {
+	if srcJSON == "" {
+		return srcJSON, nil
+	}
+
+	u, err := parseJSON(srcJSON)
+	if err != nil {
+		return "", err
+	}
+
+	gvk := u.GroupVersionKind()
+	if !isSensitiveGroupKind(gvk.Group, gvk.Kind) {
+		return srcJSON, nil
+	}
+
+	for _, field := range secretDataFields {
+		values, ok, err := k8sunstructured.NestedMap(u.Object, field)
+		if err != nil {
+			return "", fmt.Errorf("reading Secret %s failed: %s", field, err)
+		}
+		if !ok {
+			continue
+		}
+
+		for k, v := range values {
+			values[k] = redactValue(fmt.Sprintf("%v", v))
+		}
+
+		err = k8sunstructured.SetNestedMap(u.Object, values, field)
+		if err != nil {
+			return "", fmt.Errorf("redacting Secret %s failed: %s", field, err)
+		}
+	}
+
+	redacted, err := json.Marshal(u.Object)
+	if err != nil {
+		return "", err
+	}
+
+	return string(redacted), nil
+}
+
+func redactValue(v string) string {
+	// values that are already redacted are kept as is
+	if strings.HasPrefix(v, redactedValuePrefix) {
+		return v
+	}
+
+	h := sha256.Sum256([]byte(v))
+	return redactedValuePrefix + hex.EncodeToString(h[:])
+}
+
+// redactSecretManifestStateFunc stores Secret manifests redacted in the
+// state and plan. Manifests that can not be parsed are kept unchanged
+// and will surface their error from the CRUD functions.
+func redactSecretManifestStateFunc(v interface{}) string {
+	srcJSON := v.(string)
+
+	redacted, err := redactSecretManifest(srcJSON)
+	if err != nil {
+		return srcJSON
+	}
+
+	return redacted
+}
+
+// redactPatch returns patch with the values of a Secret's data and
+// stringData fields and its last applied configuration redacted,
+// patches of all other kinds are returned unchanged
+func redactPatch(gk k8sschema.GroupKind, patch []byte) string {
+	if !isSensitiveGroupKind(gk.Group, gk.Kind) {
+		return string(patch)
+	}
+
+	var p map[string]interface{}
+	err := json.Unmarshal(patch, &p)
+	if err != nil {
+		return "(sensitive patch)"
+	}
+
+	for _, field := range secretDataFields {
+		data, ok := p[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range data {
+			if s, ok := v.(string); ok {
+				data[k] = redactValue(s)
+			}
+		}
+	}
+
+	annotations, _, _ := k8sunstructured.NestedMap(p, "metadata", "annotations")
+	if lac, ok := annotations[lastAppliedConfig].(string); ok {
+		redacted, err := redactSecretManifest(lac)
+		if err != nil {
+			redacted = "(sensitive value)"
+		}
+		k8sunstructured.SetNestedField(p, redacted, "metadata", "annotations", lastAppliedConfig)
+	}
+
+	redacted, err := json.Marshal(p)
+	if err != nil {
+		return "(sensitive patch)"
+	}
+
+	return string(redacted)
+}
+
+// redactError returns the message of err. Messages of API errors about
+// invalid field values include the values, for Secrets only the kind,
+// name and the invalid fields of the error's details are returned.
+func redactError(gk k8sschema.GroupKind, err error) string {
+	if !isSensitiveGroupKind(gk.Group, gk.Kind) {
+		return err.Error()
+	}
+
+	status, ok := err.(k8serrors.APIStatus)
+	if !ok || status.Status().Details == nil {
+		return "(sensitive error)"
+	}
+
+	details := status.Status().Details
+	msg := fmt.Sprintf("(sensitive error) %s %q", details.Kind, details.Name)
+
+	var fields []string
+	for _, c := range details.Causes {
+		fields = append(fields, fmt.Sprintf("%s: %s", c.Field, c.Type))
+	}
+	if len(fields) > 0 {
+		msg = fmt.Sprintf("%s: %s", msg, strings.Join(fields, ", "))
+	}
+
+	return msg
+}
+
+// lastAppliedConfigFor returns the value for the last applied
+// configuration annotation, optionally omitting Secret payloads
+func lastAppliedConfigFor(srcJSON string, omitSecretData bool) (string, error) {
+	if !omitSecretData {
+		return srcJSON, nil
+	}
+
+	return redactSecretManifest(srcJSON)
+}
+
 func setLastAppliedConfig(u *k8sunstructured.Unstructured, srcJSON string) {
 	annotations := u.GetAnnotations()
 	if len(annotations) == 0 {
@@ -29,7 +198,7 @@ func getLastAppliedConfig(u *k8sunstructured.Unstructured) string {
 	return u.GetAnnotations()[lastAppliedConfig]
 }
 
-func getOriginalModifiedCurrent(originalJSON string, modifiedJSON string, currentAllowNotFound bool, m interface{}) (original []byte, modified []byte, current []byte, err error) {
+func getOriginalModifiedCurrent(originalJSON string, modifiedJSON string, currentAllowNotFound bool, omitSecretData bool, logger resourceLogger, m interface{}) (original []byte, modified []byte, current []byte, err error) {
 	client := m.(*Config).Client
 	cgvk := m.(*Config).CachedGroupVersionKind
 
@@ -42,8 +211,17 @@ func getOriginalModifiedCurrent(originalJSON string, modifiedJSON string, curren
 		return nil, nil, nil, err
 	}
 
-	setLastAppliedConfig(o, originalJSON)
-	setLastAppliedConfig(n, modifiedJSON)
+	originalLAC, err := lastAppliedConfigFor(originalJSON, omitSecretData)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	modifiedLAC, err := lastAppliedConfigFor(modifiedJSON, omitSecretData)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	setLastAppliedConfig(o, originalLAC)
+	setLastAppliedConfig(n, modifiedLAC)
 
 	gvr, err := cgvk.getGVR(o.GroupVersionKind(), false)
 	if err != nil {
@@ -62,10 +240,12 @@ func getOriginalModifiedCurrent(originalJSON string, modifiedJSON string, curren
 		return nil, nil, nil, err
 	}
 
+	start := time.Now()
 	c, err := client.
 		Resource(gvr).
 		Namespace(namespace).
 		Get(context.TODO(), name, k8smetav1.GetOptions{})
+	logger.apiCall("get", gvr, namespace, name, start, err)
 	if err != nil {
 		if k8serrors.IsNotFound(err) && currentAllowNotFound {
 			return original, modified, current, nil
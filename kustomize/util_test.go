@@ -1,7 +1,10 @@
 package kustomize
 
 import (
+	"strings"
 	"testing"
+
+	k8sunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func TestLastAppliedConfig(t *testing.T) {
@@ -40,3 +43,49 @@ func TestGetPatch(t *testing.T) {
 		t.Errorf("TestGetPatch: %s", err)
 	}
 }
+
+func TestRedactSecretManifest(t *testing.T) {
+	srcJSON := "{\"apiVersion\": \"v1\", \"kind\": \"Secret\", \"metadata\": {\"name\": \"test-unit\"}, \"data\": {\"password\": \"c2VjcmV0\"}, \"stringData\": {\"user\": \"admin\"}}"
+
+	redacted, err := redactSecretManifest(srcJSON)
+	if err != nil {
+		t.Errorf("TestRedactSecretManifest: %s", err)
+	}
+
+	if strings.Contains(redacted, "c2VjcmV0") || strings.Contains(redacted, "admin") {
+		t.Errorf("TestRedactSecretManifest: payload not redacted: %s", redacted)
+	}
+
+	u, _ := parseJSON(redacted)
+	password, _, _ := k8sunstructured.NestedString(u.Object, "data", "password")
+	if !strings.HasPrefix(password, redactedValuePrefix) {
+		t.Errorf("TestRedactSecretManifest: incorrect redacted value, got: %s, want prefix: %s.", password, redactedValuePrefix)
+	}
+
+	again, err := redactSecretManifest(redacted)
+	if err != nil {
+		t.Errorf("TestRedactSecretManifest: %s", err)
+	}
+	if again != redacted {
+		t.Errorf("TestRedactSecretManifest: redacting is not idempotent, got: %s, want: %s.", again, redacted)
+	}
+
+	changedJSON := strings.Replace(srcJSON, "c2VjcmV0", "Y2hhbmdlZA==", 1)
+	changed, _ := redactSecretManifest(changedJSON)
+	if changed == redacted {
+		t.Errorf("TestRedactSecretManifest: changed payload results in identical hash")
+	}
+}
+
+func TestRedactSecretManifestOtherKinds(t *testing.T) {
+	srcJSON := "{\"apiVersion\": \"v1\", \"kind\": \"ConfigMap\", \"metadata\": {\"name\": \"test-unit\"}, \"data\": {\"key\": \"value\"}}"
+
+	redacted, err := redactSecretManifest(srcJSON)
+	if err != nil {
+		t.Errorf("TestRedactSecretManifestOtherKinds: %s", err)
+	}
+
+	if redacted != srcJSON {
+		t.Errorf("TestRedactSecretManifestOtherKinds: incorrect manifest, got: %s, want: %s.", redacted, srcJSON)
+	}
+}
@@ -1,12 +1,40 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+
 	"github.com/hashicorp/terraform-plugin-sdk/plugin"
 	"github.com/hashicorp/terraform-plugin-sdk/terraform"
 	"github.com/kbst/terraform-provider-kustomize/kustomize"
 )
 
 func main() {
+	subcommands := map[string]func([]string, io.Writer) error{
+		"render":  kustomize.Render,
+		"imports": kustomize.Imports,
+	}
+
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			// debug logs are for the provider, only write them
+			// when requested like Terraform does
+			if os.Getenv("TF_LOG") == "" {
+				log.SetOutput(ioutil.Discard)
+			}
+
+			err := subcommand(os.Args[2:], os.Stdout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	plugin.Serve(&plugin.ServeOpts{
 		ProviderFunc: func() terraform.ResourceProvider {
 			return kustomize.Provider()
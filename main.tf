@@ -5,5 +5,8 @@ data "kustomization" "test" {
 resource "kustomization_resource" "test" {
   for_each = data.kustomization.test.ids
 
-  manifest = data.kustomization.test.manifests[each.value]
+  manifest = merge(
+    data.kustomization.test.manifests,
+    data.kustomization.test.sensitive_manifests
+  )[each.value]
 }